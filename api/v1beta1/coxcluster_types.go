@@ -46,6 +46,157 @@ type CoxClusterSpec struct {
 	ControlPlaneLoadBalancer CoxLoadBalancerSpec `json:"controlPlaneLoadBalancer,omitempty"`
 
 	WorkersLoadBalancer CoxLoadBalancerSpec `json:"workersLoadBalancer,omitempty"`
+
+	// DNS is optional configuration for publishing the control plane load
+	// balancer under a stable name. If set, the ControlPlaneEndpoint host is
+	// set to DNS.FQDN instead of the public IP of the load balancer.
+	// +optional
+	DNS *DNSSpec `json:"dns,omitempty"`
+
+	// FailureDomains are the POPs that machines of the cluster can be spread
+	// across. Machines placed in a failure domain are deployed to its POP,
+	// unless their deployments pin POPs themselves.
+	// +optional
+	FailureDomains []CoxFailureDomain `json:"failureDomains,omitempty"`
+
+	// NetworkPolicy restricts the inbound traffic to the workloads of the
+	// cluster. If unset, the ports of the workloads are open to everyone.
+	// +optional
+	NetworkPolicy *CoxNetworkPolicy `json:"networkPolicy,omitempty"`
+
+	// Network is the private network the workloads of the cluster are
+	// attached to. If unset, they are attached to the default VPC.
+	// +optional
+	Network *CoxNetworkSpec `json:"network,omitempty"`
+}
+
+// CoxNetworkSpec defines the VPC and subnets of the cluster.
+type CoxNetworkSpec struct {
+	// VPC of the cluster.
+	VPC CoxVPCSpec `json:"vpc"`
+
+	// Subnets of the VPC. Workloads are attached to the first subnet, unless
+	// their network interfaces select another one.
+	// +optional
+	Subnets []CoxSubnetSpec `json:"subnets,omitempty"`
+}
+
+// CoxVPCSpec references an existing VPC, or defines the VPC to create.
+type CoxVPCSpec struct {
+	// Slug of the VPC.
+	Slug string `json:"slug"`
+
+	// Name of the VPC. Defaults to the slug.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// CIDR of the VPC. If set, the VPC is created if it does not exist.
+	// Otherwise the VPC has to exist already. Only a VPC that has been created
+	// for the cluster is deleted with it.
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+}
+
+// CoxSubnetSpec references an existing subnet, or defines the subnet to create.
+type CoxSubnetSpec struct {
+	// Slug of the subnet.
+	Slug string `json:"slug"`
+
+	// Name of the subnet. Defaults to the slug.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// CIDR of the subnet. If set, the subnet is created if it does not exist.
+	// Otherwise the subnet has to exist already. Only a subnet that has been
+	// created for the cluster is deleted with it.
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+}
+
+// CoxNetworkPolicy defines the inbound traffic allowed to the workloads of
+// the cluster. Traffic that is not allowed by any rule is blocked.
+type CoxNetworkPolicy struct {
+	// Ingress rules applied to the machines of the cluster. The machines of
+	// the cluster always accept any traffic from each other and from the VPC
+	// of the cluster, the control plane load balancer is always allowed to
+	// reach the API server of the control plane machines and the workers load
+	// balancer the backend ports of the workers.
+	// +optional
+	Ingress []CoxIngressRule `json:"ingress,omitempty"`
+
+	// APIServerAllowedCIDRs restricts access to the control plane endpoint to
+	// the given CIDRs. Defaults to 0.0.0.0/0.
+	// +optional
+	APIServerAllowedCIDRs []string `json:"apiServerAllowedCIDRs,omitempty"`
+}
+
+// CoxIngressRule allows inbound traffic on a port range from a set of CIDRs.
+type CoxIngressRule struct {
+	// Description of the rule.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Protocol of the traffic. Defaults to TCP.
+	// +kubebuilder:validation:Enum=TCP;UDP;TCP_UDP;ANY
+	// +optional
+	Protocol string `json:"protocol,omitempty"`
+
+	// PortRange is a single port or a range of ports, for example 22 or
+	// 30000-32767. It is ignored for the ANY protocol.
+	// +kubebuilder:validation:Pattern=`^[0-9]+(-[0-9]+)?$`
+	// +optional
+	PortRange string `json:"portRange,omitempty"`
+
+	// Sources are the CIDRs the traffic is allowed from. Defaults to 0.0.0.0/0.
+	// +optional
+	Sources []string `json:"sources,omitempty"`
+}
+
+// CoxFailureDomain is a Cox Edge POP exposed as a Cluster API failure domain.
+type CoxFailureDomain struct {
+	// POP is the code of the Cox Edge point of presence, for example LAX.
+	POP string `json:"pop"`
+
+	// ControlPlane determines whether the POP is eligible for control plane
+	// machines. Defaults to true.
+	// +optional
+	ControlPlane *bool `json:"controlPlane,omitempty"`
+}
+
+// DNSSpec defines the DNS record that points to the control plane load balancer.
+type DNSSpec struct {
+	// FQDN is the fully qualified domain name of the control plane endpoint.
+	FQDN string `json:"fqdn"`
+
+	// TTL of the record in seconds. Defaults to 60.
+	// +optional
+	TTL int32 `json:"ttl,omitempty"`
+
+	// RFC2136 manages the record through RFC2136 dynamic updates.
+	// +optional
+	RFC2136 *RFC2136DNSProvider `json:"rfc2136,omitempty"`
+}
+
+// RFC2136DNSProvider configures a DNS server that accepts RFC2136 dynamic updates.
+type RFC2136DNSProvider struct {
+	// Server is the address of the DNS server, for example ns1.example.com:53.
+	Server string `json:"server"`
+
+	// Zone that contains the record.
+	Zone string `json:"zone"`
+
+	// TSIGKeyName is the name of the TSIG key used to sign updates.
+	// +optional
+	TSIGKeyName string `json:"tsigKeyName,omitempty"`
+
+	// TSIGAlgorithm is the algorithm of the TSIG key. Defaults to hmac-sha256.
+	// +optional
+	TSIGAlgorithm string `json:"tsigAlgorithm,omitempty"`
+
+	// TSIGSecretRef references the key in a Secret containing the base64
+	// encoded TSIG secret.
+	// +optional
+	TSIGSecretRef *corev1.SecretKeySelector `json:"tsigSecretRef,omitempty"`
 }
 
 // CoxClusterStatus defines the observed state of CoxCluster
@@ -62,6 +213,41 @@ type CoxClusterStatus struct {
 	ControlPlaneLoadBalancer CoxLoadBalancerStatus `json:"controlPlaneLoadBalancer,omitempty"`
 
 	WorkersLoadBalancer CoxLoadBalancerStatus `json:"workersLoadBalancer,omitempty"`
+
+	// ControlPlaneAddresses contains the external addresses of the control
+	// plane machines. It is kept up to date regardless of the load balancer
+	// type, so that external automation can program its own load balancer.
+	// +optional
+	ControlPlaneAddresses []string `json:"controlPlaneAddresses,omitempty"`
+
+	// FailureDomains are the POPs machines of the cluster can be placed in.
+	// +optional
+	FailureDomains clusterv1beta1.FailureDomains `json:"failureDomains,omitempty"`
+
+	// Network contains the IDs of the VPC and subnets of the cluster.
+	// +optional
+	Network *CoxNetworkStatus `json:"network,omitempty"`
+}
+
+// CoxNetworkStatus defines the observed state of the network of the cluster.
+type CoxNetworkStatus struct {
+	// VPCID is the ID of the VPC of the cluster.
+	// +optional
+	VPCID string `json:"vpcID,omitempty"`
+
+	// SubnetIDs maps the slugs of the subnets of the cluster to their IDs.
+	// +optional
+	SubnetIDs map[string]string `json:"subnetIDs,omitempty"`
+
+	// CreatedVPC reports whether the VPC has been created for the cluster,
+	// in which case it is deleted with the cluster.
+	// +optional
+	CreatedVPC bool `json:"createdVPC,omitempty"`
+
+	// CreatedSubnets are the slugs of the subnets that have been created for
+	// the cluster, which are deleted with the cluster.
+	// +optional
+	CreatedSubnets []string `json:"createdSubnets,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -70,6 +256,9 @@ type CoxClusterStatus struct {
 // +kubebuilder:printcolumn:name="Credentials",type="string",JSONPath=".spec.credentials.name",description="Cluster Credentials"
 // +kubebuilder:printcolumn:name="Endpoint",type="string",JSONPath=".spec.controlPlaneEndpoint",description="API Endpoint"
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Cluster infrastructure is ready for Cox instances"
+// +kubebuilder:printcolumn:name="ControlPlaneLB",type="string",JSONPath=".status.conditions[?(@.type==\"ControlPlaneLoadBalancerReady\")].status",description="Control plane load balancer is ready"
+// +kubebuilder:printcolumn:name="WorkersLB",type="string",JSONPath=".status.conditions[?(@.type==\"WorkersLoadBalancerReady\")].status",description="Workers load balancer is ready"
+// +kubebuilder:printcolumn:name="LBInstances",type="string",JSONPath=".status.controlPlaneLoadBalancer.readyInstances",description="Running control plane load balancer instances",priority=1
 
 // CoxCluster is the Schema for the coxclusters API
 type CoxCluster struct {
@@ -89,10 +278,32 @@ type CoxClusterList struct {
 	Items           []CoxCluster `json:"items"`
 }
 
+// LoadBalancerType defines how the load balancer of a CoxCluster is provided.
+// +kubebuilder:validation:Enum=Managed;External
+type LoadBalancerType string
+
+const (
+	// ManagedLoadBalancerType runs the load balancer as a workload in Cox Edge.
+	ManagedLoadBalancerType LoadBalancerType = "Managed"
+
+	// ExternalLoadBalancerType relies on a load balancer that is managed
+	// outside of the provider. For the control plane load balancer the
+	// endpoint has to be provided through Spec.ControlPlaneEndpoint. For the
+	// workers load balancer no load balancer is deployed at all, and a
+	// previously deployed one is deleted.
+	ExternalLoadBalancerType LoadBalancerType = "External"
+)
+
 type CoxLoadBalancerSpec struct {
 	// +optional
 	Name string `json:"name"`
 
+	// Type of the load balancer. Defaults to Managed, unless the
+	// ControlPlaneEndpoint has been set up front, in which case the control
+	// plane load balancer defaults to External.
+	// +optional
+	Type LoadBalancerType `json:"type,omitempty"`
+
 	// +optional
 	Image string `json:"image,omitempty"`
 
@@ -102,13 +313,127 @@ type CoxLoadBalancerSpec struct {
 	// POP for instance
 	POP []string `json:"pop,omitempty"`
 
-	// Number of Instances to be launched
+	// Number of Instances to be launched in each POP if AutoScaling is not
+	// set. If neither is set, the load balancer autoscales between 1 and 3
+	// instances per POP.
 	Size string `json:"size,omitempty"`
+
+	// AutoScaling scales the number of instances in each POP based on the
+	// CPU utilization of the load balancer. It takes precedence over Size.
+	// +optional
+	AutoScaling *CoxLoadBalancerAutoScaling `json:"autoScaling,omitempty"`
+
+	// Specs contains the flavor of the load balancer instances. Defaults to SP-1.
+	// +optional
+	Specs string `json:"specs,omitempty"`
+
+	// Anycast requests an anycast IP address for the load balancer, which
+	// is used as the stable address of the load balancer across all POPs.
+	// +optional
+	Anycast bool `json:"anycast,omitempty"`
+}
+
+// CoxLoadBalancerAutoScaling defines the autoscaling behavior of a load balancer.
+type CoxLoadBalancerAutoScaling struct {
+	// MinInstancesPerPop is the minimum number of instances in each POP.
+	MinInstancesPerPop string `json:"minInstancesPerPop"`
+
+	// MaxInstancesPerPop is the maximum number of instances in each POP.
+	MaxInstancesPerPop string `json:"maxInstancesPerPop"`
+
+	// CPUUtilization is the target CPU utilization in percent. Defaults to 50.
+	// +optional
+	CPUUtilization int `json:"cpuUtilization,omitempty"`
 }
 
 type CoxLoadBalancerStatus struct {
 	// +optional
 	PublicIP string `json:"publicIP"`
+
+	// WorkloadID is the ID of the Cox Edge workload running the load balancer.
+	// +optional
+	WorkloadID string `json:"workloadID,omitempty"`
+
+	// Name of the Cox Edge workload running the load balancer.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Image the load balancer is running.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Ports the load balancer is listening on.
+	// +optional
+	Ports []string `json:"ports,omitempty"`
+
+	// Backends the load balancer is routing traffic to.
+	// +optional
+	Backends []string `json:"backends,omitempty"`
+
+	// DesiredInstances is the number of instances the load balancer should
+	// at least be running across all POPs.
+	// +optional
+	DesiredInstances int32 `json:"desiredInstances,omitempty"`
+
+	// ReadyInstances is the number of load balancer instances that are running.
+	// +optional
+	ReadyInstances int32 `json:"readyInstances,omitempty"`
+
+	// AnycastIP is the anycast IP address of the load balancer, if enabled.
+	// +optional
+	AnycastIP string `json:"anycastIP,omitempty"`
+
+	// Addresses clients should use to reach the load balancer: the anycast IP
+	// if enabled, or else the public IPs of all running instances.
+	// +optional
+	Addresses []string `json:"addresses,omitempty"`
+
+	// Instances contains the instances of the load balancer in each POP.
+	// +optional
+	Instances []CoxLoadBalancerInstanceStatus `json:"instances,omitempty"`
+
+	// Rollout describes an ongoing replacement of the load balancer.
+	// +optional
+	Rollout *CoxLoadBalancerRolloutStatus `json:"rollout,omitempty"`
+}
+
+// CoxLoadBalancerRolloutStatus describes the replacement of a load balancer
+// after a change to its image, POPs or instance spec.
+type CoxLoadBalancerRolloutStatus struct {
+	// Name of the workload that is going to replace the current load balancer.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// RetiredName is the name of the workload that has been replaced. It is
+	// deleted once clients had the time to move to the replacement.
+	// +optional
+	RetiredName string `json:"retiredName,omitempty"`
+
+	// RetiredTime is the time at which traffic was cut over to the replacement.
+	// +optional
+	RetiredTime *metav1.Time `json:"retiredTime,omitempty"`
+}
+
+// CoxLoadBalancerInstanceStatus describes a single load balancer instance.
+type CoxLoadBalancerInstanceStatus struct {
+	// Name of the instance.
+	Name string `json:"name"`
+
+	// POP the instance is running in.
+	// +optional
+	POP string `json:"pop,omitempty"`
+
+	// PublicIP of the instance.
+	// +optional
+	PublicIP string `json:"publicIP,omitempty"`
+
+	// PrivateIPs of the instance, which it reaches backends in the VPC from.
+	// +optional
+	PrivateIPs []string `json:"privateIPs,omitempty"`
+
+	// State of the instance, for example RUNNING.
+	// +optional
+	State string `json:"state,omitempty"`
 }
 
 // GetConditions returns the set of conditions for this object.
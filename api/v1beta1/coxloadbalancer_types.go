@@ -0,0 +1,172 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// LoadBalancerFinalizer allows ReconcileCoxLoadBalancer to clean up the Cox
+	// workloads of a CoxLoadBalancer before removing it from the apiserver.
+	LoadBalancerFinalizer = "coxloadbalancer.infrastructure.cluster.x-k8s.io"
+)
+
+// LoadBalancerReplacementStrategy defines how changes to a load balancer
+// that can not be applied in place are rolled out.
+// +kubebuilder:validation:Enum=BlueGreen;None
+type LoadBalancerReplacementStrategy string
+
+const (
+	// BlueGreenReplacementStrategy creates a replacement load balancer next to
+	// the current one and switches over once the replacement is running.
+	BlueGreenReplacementStrategy LoadBalancerReplacementStrategy = "BlueGreen"
+
+	// NoReplacementStrategy never replaces the load balancer. Changes to the
+	// image, POPs or specs are not applied.
+	NoReplacementStrategy LoadBalancerReplacementStrategy = "None"
+)
+
+// CoxLoadBalancerResourceSpec defines the desired state of CoxLoadBalancer
+type CoxLoadBalancerResourceSpec struct {
+	// Credentials is a reference to an identity to be used when reconciling this load balancer.
+	// +optional
+	Credentials *corev1.LocalObjectReference `json:"credentials,omitempty"`
+
+	// WorkloadName is the name of the Cox Edge workload running the load
+	// balancer. Defaults to the name of the CoxLoadBalancer.
+	// +optional
+	WorkloadName string `json:"workloadName,omitempty"`
+
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Ports the load balancer is listening on.
+	Ports []string `json:"ports"`
+
+	// Backends is a static list of host:port addresses to route traffic to.
+	// +optional
+	Backends []string `json:"backends,omitempty"`
+
+	// BackendSelector selects the CoxMachines in the namespace of the load
+	// balancer to route traffic to, in addition to Backends.
+	// +optional
+	BackendSelector *metav1.LabelSelector `json:"backendSelector,omitempty"`
+
+	// BackendPorts are the ports of the selected CoxMachines to route traffic
+	// to. Defaults to Ports.
+	// +optional
+	BackendPorts []string `json:"backendPorts,omitempty"`
+
+	// BackendAddressType is the type of the address of the selected
+	// CoxMachines to route traffic to. Defaults to InternalIP.
+	// +optional
+	BackendAddressType corev1.NodeAddressType `json:"backendAddressType,omitempty"`
+
+	// POP for instance
+	POP []string `json:"pop,omitempty"`
+
+	// Number of Instances to be launched in each POP if AutoScaling is not
+	// set. If neither is set, the load balancer autoscales between 1 and 3
+	// instances per POP.
+	// +optional
+	Size string `json:"size,omitempty"`
+
+	// AutoScaling scales the number of instances in each POP based on the
+	// CPU utilization of the load balancer. It takes precedence over Size.
+	// +optional
+	AutoScaling *CoxLoadBalancerAutoScaling `json:"autoScaling,omitempty"`
+
+	// Specs contains the flavor of the load balancer instances. Defaults to SP-1.
+	// +optional
+	Specs string `json:"specs,omitempty"`
+
+	// Anycast requests an anycast IP address for the load balancer, which
+	// is used as the stable address of the load balancer across all POPs.
+	// +optional
+	Anycast bool `json:"anycast,omitempty"`
+
+	// NetworkInterfaces attach the load balancer to VPCs when it is created.
+	// Defaults to a single interface with a public IP in the default VPC.
+	// +optional
+	NetworkInterfaces []NetworkInterface `json:"networkInterfaces,omitempty"`
+
+	// ReplacementStrategy defines how changes to the image, POPs or specs are
+	// rolled out. Defaults to BlueGreen.
+	// +optional
+	ReplacementStrategy LoadBalancerReplacementStrategy `json:"replacementStrategy,omitempty"`
+
+	// RetirementGracePeriod is the time a replaced load balancer keeps running
+	// after traffic has been switched over to its replacement. Defaults to 2m.
+	// +optional
+	RetirementGracePeriod *metav1.Duration `json:"retirementGracePeriod,omitempty"`
+}
+
+// CoxLoadBalancerResourceStatus defines the observed state of CoxLoadBalancer
+type CoxLoadBalancerResourceStatus struct {
+	// Ready denotes that the load balancer has a running instance.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// Conditions defines current service state of the load balancer.
+	// +optional
+	Conditions clusterv1beta1.Conditions `json:"conditions,omitempty"`
+
+	CoxLoadBalancerStatus `json:",inline"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this CoxLoadBalancer belongs"
+// +kubebuilder:printcolumn:name="Workload",type="string",JSONPath=".status.name",description="Cox Edge workload running the load balancer"
+// +kubebuilder:printcolumn:name="Address",type="string",JSONPath=".status.publicIP",description="Public address of the load balancer"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Load balancer has a running instance"
+// +kubebuilder:printcolumn:name="Instances",type="string",JSONPath=".status.readyInstances",description="Running load balancer instances",priority=1
+
+// CoxLoadBalancer is the Schema for the coxloadbalancers API
+type CoxLoadBalancer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CoxLoadBalancerResourceSpec   `json:"spec,omitempty"`
+	Status CoxLoadBalancerResourceStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CoxLoadBalancerList contains a list of CoxLoadBalancer
+type CoxLoadBalancerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CoxLoadBalancer `json:"items"`
+}
+
+// GetConditions returns the set of conditions for this object.
+func (m *CoxLoadBalancer) GetConditions() clusterv1beta1.Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the conditions on this object.
+func (m *CoxLoadBalancer) SetConditions(conditions clusterv1beta1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
+func init() {
+	SchemeBuilder.Register(&CoxLoadBalancer{}, &CoxLoadBalancerList{})
+}
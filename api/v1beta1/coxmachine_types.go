@@ -20,12 +20,24 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
 const (
 	// MachineFinalizer allows ReconcileCoxMachine to clean up Cox resources
 	// associated with CoxCluster before removing it from the apiserver.
 	MachineFinalizer = "coxmachine.infrastructure.cluster.x-k8s.io"
+
+	// WorkloadNonceAnnotation records the nonce a CoxMachine marks its
+	// workload with. It is persisted before the workload is requested, so
+	// that a workload whose task ID was lost is adopted instead of created
+	// a second time.
+	WorkloadNonceAnnotation = "coxmachine.infrastructure.cluster.x-k8s.io/workload-nonce"
+
+	// WorkloadRequestedAnnotation records when the workload of a CoxMachine
+	// was last requested, in RFC 3339 format. The workload is not requested
+	// again as long as the annotation is set.
+	WorkloadRequestedAnnotation = "coxmachine.infrastructure.cluster.x-k8s.io/workload-requested"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -49,6 +61,11 @@ type CoxMachineSpec struct {
 	// +optional
 	Ports []Port `json:"ports,omitempty"`
 
+	// NetworkInterfaces attach the machine to VPCs. Defaults to a single
+	// interface with a public IP in the VPC of the cluster.
+	// +optional
+	NetworkInterfaces []NetworkInterface `json:"networkInterfaces,omitempty"`
+
 	// SSHAuthorizedKeys contains the public SSH keys that should be added to
 	// the machine on first boot. In the CoxEdge API this field is equivalent
 	// to `firstBootSSHKey`.
@@ -68,9 +85,29 @@ type CoxMachineSpec struct {
 	// +optional
 	Image string `json:"image,omitempty"`
 
-	// User data compatible with cloud-init
+	// AdditionalUserData is cloud-init user data merged with the bootstrap
+	// data of the machine, for example to install packages, set sysctls or
+	// add mounts. Lists of additional cloud-config are appended to those of
+	// the bootstrap data, whose other keys take precedence.
+	// +optional
+	AdditionalUserData *UserDataSource `json:"additionalUserData,omitempty"`
+}
+
+// UserDataSource provides cloud-init user data inline or from a key of a
+// Secret or ConfigMap in the namespace of the machine. Only one of its fields
+// may be set.
+type UserDataSource struct {
+	// Value is the inline user data.
 	// +optional
-	// UserData string `json:"userData,omitempty"`
+	Value string `json:"value,omitempty"`
+
+	// SecretKeyRef selects the key of a Secret holding the user data.
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+
+	// ConfigMapKeyRef selects the key of a ConfigMap holding the user data.
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
 }
 
 // Deployment defines instance specifications
@@ -107,6 +144,31 @@ type Port struct {
 	PublicPortDesc string `json:"publicPortDesc,omitempty"`
 }
 
+// NetworkInterface attaches a workload to a VPC.
+type NetworkInterface struct {
+	// VPCSlug is the slug of the VPC. Defaults to the VPC of the cluster, or
+	// to the default VPC of the environment.
+	// +optional
+	VPCSlug string `json:"vpcSlug,omitempty"`
+
+	// SubnetSlug is the slug of the subnet in the VPC. Defaults to the first
+	// subnet of the cluster, if the interface is attached to its VPC.
+	// +optional
+	SubnetSlug string `json:"subnetSlug,omitempty"`
+
+	// IPFamilies of the interface: IPv4, IPv6, or IPv4,IPv6 for dual-stack.
+	// Defaults to IPv4.
+	// +kubebuilder:validation:Enum=IPv4;IPv6;"IPv4,IPv6"
+	// +optional
+	IPFamilies string `json:"ipFamilies,omitempty"`
+
+	// IsPublicIP determines whether the interface gets a public IP. Defaults
+	// to true. Machines without a public IP can only be reached from within
+	// the VPC and through the load balancers of the cluster.
+	// +optional
+	IsPublicIP *bool `json:"isPublicIP,omitempty"`
+}
+
 // PersistentStorage defines instances' mounted persistent storage options
 type PersistentStorage struct {
 	Path string `json:"path"`
@@ -117,9 +179,27 @@ type PersistentStorage struct {
 type CoxMachineStatus struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
-	TaskID       string  `json:"taskID,omitempty"`
-	TaskStatus   string  `json:"taskStatus,omitempty"`
-	Ready        bool    `json:"ready,omitempty"`
+	TaskID     string `json:"taskID,omitempty"`
+	TaskStatus string `json:"taskStatus,omitempty"`
+	Ready      bool   `json:"ready,omitempty"`
+
+	// FailureReason will be set in the event that there is a terminal problem
+	// reconciling the CoxMachine, such as a failed provisioning task or an
+	// invalid spec, and will contain a succinct value suitable for machine
+	// interpretation. Transient errors are only reported in the conditions.
+	// +optional
+	FailureReason *capierrors.MachineStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage will be set in the event that there is a terminal problem
+	// reconciling the CoxMachine and will contain a more verbose string
+	// suitable for logging and human consumption.
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// ErrorMessage is the terminal error of machines failed by older versions.
+	// Deprecated: use FailureReason and FailureMessage instead. It is no longer
+	// set, and is moved to FailureMessage when the machine is reconciled.
+	// +optional
 	ErrorMessage *string `json:"errormessage,omitempty"`
 
 	// Conditions defines current service state of the Machine.
@@ -128,6 +208,11 @@ type CoxMachineStatus struct {
 
 	// Addresses contains the IP and/or DNS addresses of the CoxEdge instances.
 	Addresses []corev1.NodeAddress `json:"addresses,omitempty"`
+
+	// InstanceID is the ID of the CoxEdge instance running the machine. It is
+	// used to find the Node of the machine by its system UUID.
+	// +optional
+	InstanceID string `json:"instanceID,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -136,6 +221,7 @@ type CoxMachineStatus struct {
 // +kubebuilder:printcolumn:name="Machine",type="string",JSONPath=".metadata.ownerReferences[?(@.kind==\"Machine\")].name",description="Machine object which owns with this CoxMachine"
 // +kubebuilder:printcolumn:name="WorkloadID",type="string",JSONPath=".spec.providerID",description="CoxEdge workload ID"
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Machine ready status"
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason",description="Provisioning step the machine is waiting for"
 
 // CoxMachine is the Schema for the coxmachines API
 type CoxMachine struct {
@@ -7,8 +7,10 @@ package v1beta1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	apiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/errors"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -80,6 +82,29 @@ func (in *CoxClusterSpec) DeepCopyInto(out *CoxClusterSpec) {
 		**out = **in
 	}
 	in.ControlPlaneLoadBalancer.DeepCopyInto(&out.ControlPlaneLoadBalancer)
+	in.WorkersLoadBalancer.DeepCopyInto(&out.WorkersLoadBalancer)
+	if in.DNS != nil {
+		in, out := &in.DNS, &out.DNS
+		*out = new(DNSSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailureDomains != nil {
+		in, out := &in.FailureDomains, &out.FailureDomains
+		*out = make([]CoxFailureDomain, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(CoxNetworkPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Network != nil {
+		in, out := &in.Network, &out.Network
+		*out = new(CoxNetworkSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxClusterSpec.
@@ -102,7 +127,25 @@ func (in *CoxClusterStatus) DeepCopyInto(out *CoxClusterStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	out.ControlPlaneLoadBalancer = in.ControlPlaneLoadBalancer
+	in.ControlPlaneLoadBalancer.DeepCopyInto(&out.ControlPlaneLoadBalancer)
+	in.WorkersLoadBalancer.DeepCopyInto(&out.WorkersLoadBalancer)
+	if in.ControlPlaneAddresses != nil {
+		in, out := &in.ControlPlaneAddresses, &out.ControlPlaneAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailureDomains != nil {
+		in, out := &in.FailureDomains, &out.FailureDomains
+		*out = make(apiv1beta1.FailureDomains, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Network != nil {
+		in, out := &in.Network, &out.Network
+		*out = new(CoxNetworkStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxClusterStatus.
@@ -115,14 +158,262 @@ func (in *CoxClusterStatus) DeepCopy() *CoxClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxFailureDomain) DeepCopyInto(out *CoxFailureDomain) {
+	*out = *in
+	if in.ControlPlane != nil {
+		in, out := &in.ControlPlane, &out.ControlPlane
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxFailureDomain.
+func (in *CoxFailureDomain) DeepCopy() *CoxFailureDomain {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxFailureDomain)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxIngressRule) DeepCopyInto(out *CoxIngressRule) {
+	*out = *in
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxIngressRule.
+func (in *CoxIngressRule) DeepCopy() *CoxIngressRule {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxIngressRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxLoadBalancer) DeepCopyInto(out *CoxLoadBalancer) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxLoadBalancer.
+func (in *CoxLoadBalancer) DeepCopy() *CoxLoadBalancer {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxLoadBalancer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CoxLoadBalancer) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxLoadBalancerAutoScaling) DeepCopyInto(out *CoxLoadBalancerAutoScaling) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxLoadBalancerAutoScaling.
+func (in *CoxLoadBalancerAutoScaling) DeepCopy() *CoxLoadBalancerAutoScaling {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxLoadBalancerAutoScaling)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxLoadBalancerInstanceStatus) DeepCopyInto(out *CoxLoadBalancerInstanceStatus) {
+	*out = *in
+	if in.PrivateIPs != nil {
+		in, out := &in.PrivateIPs, &out.PrivateIPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxLoadBalancerInstanceStatus.
+func (in *CoxLoadBalancerInstanceStatus) DeepCopy() *CoxLoadBalancerInstanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxLoadBalancerInstanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxLoadBalancerList) DeepCopyInto(out *CoxLoadBalancerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CoxLoadBalancer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxLoadBalancerList.
+func (in *CoxLoadBalancerList) DeepCopy() *CoxLoadBalancerList {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxLoadBalancerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CoxLoadBalancerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxLoadBalancerResourceSpec) DeepCopyInto(out *CoxLoadBalancerResourceSpec) {
+	*out = *in
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Backends != nil {
+		in, out := &in.Backends, &out.Backends
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BackendSelector != nil {
+		in, out := &in.BackendSelector, &out.BackendSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BackendPorts != nil {
+		in, out := &in.BackendPorts, &out.BackendPorts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.POP != nil {
+		in, out := &in.POP, &out.POP
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AutoScaling != nil {
+		in, out := &in.AutoScaling, &out.AutoScaling
+		*out = new(CoxLoadBalancerAutoScaling)
+		**out = **in
+	}
+	if in.NetworkInterfaces != nil {
+		in, out := &in.NetworkInterfaces, &out.NetworkInterfaces
+		*out = make([]NetworkInterface, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RetirementGracePeriod != nil {
+		in, out := &in.RetirementGracePeriod, &out.RetirementGracePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxLoadBalancerResourceSpec.
+func (in *CoxLoadBalancerResourceSpec) DeepCopy() *CoxLoadBalancerResourceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxLoadBalancerResourceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxLoadBalancerResourceStatus) DeepCopyInto(out *CoxLoadBalancerResourceStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.CoxLoadBalancerStatus.DeepCopyInto(&out.CoxLoadBalancerStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxLoadBalancerResourceStatus.
+func (in *CoxLoadBalancerResourceStatus) DeepCopy() *CoxLoadBalancerResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxLoadBalancerResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxLoadBalancerRolloutStatus) DeepCopyInto(out *CoxLoadBalancerRolloutStatus) {
+	*out = *in
+	if in.RetiredTime != nil {
+		in, out := &in.RetiredTime, &out.RetiredTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxLoadBalancerRolloutStatus.
+func (in *CoxLoadBalancerRolloutStatus) DeepCopy() *CoxLoadBalancerRolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxLoadBalancerRolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CoxLoadBalancerSpec) DeepCopyInto(out *CoxLoadBalancerSpec) {
 	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.POP != nil {
 		in, out := &in.POP, &out.POP
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AutoScaling != nil {
+		in, out := &in.AutoScaling, &out.AutoScaling
+		*out = new(CoxLoadBalancerAutoScaling)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxLoadBalancerSpec.
@@ -138,6 +429,33 @@ func (in *CoxLoadBalancerSpec) DeepCopy() *CoxLoadBalancerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CoxLoadBalancerStatus) DeepCopyInto(out *CoxLoadBalancerStatus) {
 	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Backends != nil {
+		in, out := &in.Backends, &out.Backends
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = make([]CoxLoadBalancerInstanceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(CoxLoadBalancerRolloutStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxLoadBalancerStatus.
@@ -222,6 +540,13 @@ func (in *CoxMachineSpec) DeepCopyInto(out *CoxMachineSpec) {
 		*out = make([]Port, len(*in))
 		copy(*out, *in)
 	}
+	if in.NetworkInterfaces != nil {
+		in, out := &in.NetworkInterfaces, &out.NetworkInterfaces
+		*out = make([]NetworkInterface, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.SSHAuthorizedKeys != nil {
 		in, out := &in.SSHAuthorizedKeys, &out.SSHAuthorizedKeys
 		*out = make([]string, len(*in))
@@ -234,6 +559,11 @@ func (in *CoxMachineSpec) DeepCopyInto(out *CoxMachineSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.AdditionalUserData != nil {
+		in, out := &in.AdditionalUserData, &out.AdditionalUserData
+		*out = new(UserDataSource)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxMachineSpec.
@@ -249,6 +579,16 @@ func (in *CoxMachineSpec) DeepCopy() *CoxMachineSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CoxMachineStatus) DeepCopyInto(out *CoxMachineStatus) {
 	*out = *in
+	if in.FailureReason != nil {
+		in, out := &in.FailureReason, &out.FailureReason
+		*out = new(errors.MachineStatusError)
+		**out = **in
+	}
+	if in.FailureMessage != nil {
+		in, out := &in.FailureMessage, &out.FailureMessage
+		*out = new(string)
+		**out = **in
+	}
 	if in.ErrorMessage != nil {
 		in, out := &in.ErrorMessage, &out.ErrorMessage
 		*out = new(string)
@@ -368,6 +708,131 @@ func (in *CoxMachineTemplateSpec) DeepCopy() *CoxMachineTemplateSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxNetworkPolicy) DeepCopyInto(out *CoxNetworkPolicy) {
+	*out = *in
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = make([]CoxIngressRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.APIServerAllowedCIDRs != nil {
+		in, out := &in.APIServerAllowedCIDRs, &out.APIServerAllowedCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxNetworkPolicy.
+func (in *CoxNetworkPolicy) DeepCopy() *CoxNetworkPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxNetworkPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxNetworkSpec) DeepCopyInto(out *CoxNetworkSpec) {
+	*out = *in
+	out.VPC = in.VPC
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]CoxSubnetSpec, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxNetworkSpec.
+func (in *CoxNetworkSpec) DeepCopy() *CoxNetworkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxNetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxNetworkStatus) DeepCopyInto(out *CoxNetworkStatus) {
+	*out = *in
+	if in.SubnetIDs != nil {
+		in, out := &in.SubnetIDs, &out.SubnetIDs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.CreatedSubnets != nil {
+		in, out := &in.CreatedSubnets, &out.CreatedSubnets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxNetworkStatus.
+func (in *CoxNetworkStatus) DeepCopy() *CoxNetworkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxNetworkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxSubnetSpec) DeepCopyInto(out *CoxSubnetSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxSubnetSpec.
+func (in *CoxSubnetSpec) DeepCopy() *CoxSubnetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxSubnetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoxVPCSpec) DeepCopyInto(out *CoxVPCSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoxVPCSpec.
+func (in *CoxVPCSpec) DeepCopy() *CoxVPCSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CoxVPCSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSSpec) DeepCopyInto(out *DNSSpec) {
+	*out = *in
+	if in.RFC2136 != nil {
+		in, out := &in.RFC2136, &out.RFC2136
+		*out = new(RFC2136DNSProvider)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSSpec.
+func (in *DNSSpec) DeepCopy() *DNSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Deployment) DeepCopyInto(out *Deployment) {
 	*out = *in
@@ -388,6 +853,26 @@ func (in *Deployment) DeepCopy() *Deployment {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkInterface) DeepCopyInto(out *NetworkInterface) {
+	*out = *in
+	if in.IsPublicIP != nil {
+		in, out := &in.IsPublicIP, &out.IsPublicIP
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterface.
+func (in *NetworkInterface) DeepCopy() *NetworkInterface {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkInterface)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PersistentStorage) DeepCopyInto(out *PersistentStorage) {
 	*out = *in
@@ -417,3 +902,48 @@ func (in *Port) DeepCopy() *Port {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RFC2136DNSProvider) DeepCopyInto(out *RFC2136DNSProvider) {
+	*out = *in
+	if in.TSIGSecretRef != nil {
+		in, out := &in.TSIGSecretRef, &out.TSIGSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RFC2136DNSProvider.
+func (in *RFC2136DNSProvider) DeepCopy() *RFC2136DNSProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(RFC2136DNSProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserDataSource) DeepCopyInto(out *UserDataSource) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserDataSource.
+func (in *UserDataSource) DeepCopy() *UserDataSource {
+	if in == nil {
+		return nil
+	}
+	out := new(UserDataSource)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -18,24 +18,33 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -50,8 +59,22 @@ const (
 	defaultWorkerLBPort      = 80
 	defaultBackend           = "example.com:80"
 	defaultLoadBalancerImage = "erwinvaneyk/nginx-lb:latest"
+	dnsTimeout               = 30 * time.Second
+
+	defaultLoadBalancerCPUUtilization     = 50
+	defaultLoadBalancerMinInstancesPerPop = "1"
+	defaultLoadBalancerMaxInstancesPerPop = "3"
 
 	CoxClusterReadyCondition clusterv1.ConditionType = "CoxClusterReady"
+	// ControlPlaneLoadBalancerReadyCondition reports on the state of the control plane load balancer
+	ControlPlaneLoadBalancerReadyCondition clusterv1.ConditionType = "ControlPlaneLoadBalancerReady"
+	// WorkersLoadBalancerReadyCondition reports on the state of the workers load balancer
+	WorkersLoadBalancerReadyCondition clusterv1.ConditionType = "WorkersLoadBalancerReady"
+	// ControlPlaneLoadBalancerUpToDateCondition reports whether the control plane load balancer matches its spec
+	ControlPlaneLoadBalancerUpToDateCondition clusterv1.ConditionType = "ControlPlaneLoadBalancerUpToDate"
+	// WorkersLoadBalancerUpToDateCondition reports whether the workers load balancer matches its spec
+	WorkersLoadBalancerUpToDateCondition clusterv1.ConditionType = "WorkersLoadBalancerUpToDate"
+
 	// LoadBalancerNotFoundReason used when LoadBalancerHelper can not find the LoadBalancer
 	LoadBalancerNotFoundReason = "LoadBalancerNotFound"
 	// LoadBalancerCreateFailedReason used when LoadBalancerHelper fails to create a LoadBalancer
@@ -66,6 +89,45 @@ const (
 	LoadBalancerInvalidBackendReason = "LoadBalancerInvalidBackend"
 	// MachineListFailedReason indicates that the controller could not list the machines
 	MachineListFailedReason = "MachineListFailed"
+	// ControlPlaneEndpointNotSetReason used when an external control plane load balancer is used without an endpoint
+	ControlPlaneEndpointNotSetReason = "ControlPlaneEndpointNotSet"
+	// DNSRecordUpdateFailedReason used when the DNS record of the control plane endpoint could not be updated
+	DNSRecordUpdateFailedReason = "DNSRecordUpdateFailed"
+	// LoadBalancerRolloutInProgressReason used while a replacement LoadBalancer is being created
+	LoadBalancerRolloutInProgressReason = "LoadBalancerRolloutInProgress"
+	// LoadBalancerRolloutFailedReason used when the replacement LoadBalancer could not be created
+	LoadBalancerRolloutFailedReason = "LoadBalancerRolloutFailed"
+	// LoadBalancerRolloutBlockedReason used when the LoadBalancer can not be replaced without changing its address
+	LoadBalancerRolloutBlockedReason = "LoadBalancerRolloutBlocked"
+	// RetiringLoadBalancerReason used while waiting to delete a LoadBalancer that has been replaced
+	RetiringLoadBalancerReason = "RetiringLoadBalancer"
+	// DeletingLoadBalancerReason used while a LoadBalancer that is no longer needed is being deleted
+	DeletingLoadBalancerReason = "DeletingLoadBalancer"
+
+	// NetworkPolicyReadyCondition reports whether the network policy has been applied to the workloads of the cluster
+	NetworkPolicyReadyCondition clusterv1.ConditionType = "NetworkPolicyReady"
+	// NetworkPolicyReconcileFailedReason used when the network policy rules of a workload could not be reconciled
+	NetworkPolicyReconcileFailedReason = "NetworkPolicyReconcileFailed"
+
+	// NetworkReadyCondition reports whether the VPC and subnets of the cluster are available
+	NetworkReadyCondition clusterv1.ConditionType = "NetworkReady"
+	// CreatingNetworkReason used while the VPC or subnets of the cluster are being created
+	CreatingNetworkReason = "CreatingNetwork"
+	// NetworkNotFoundReason used when a VPC or subnet referenced by the cluster does not exist
+	NetworkNotFoundReason = "NetworkNotFound"
+	// NetworkReconcileFailedReason used when the VPC or subnets of the cluster could not be reconciled
+	NetworkReconcileFailedReason = "NetworkReconcileFailed"
+
+	// DeletingCondition reports the progress of the deletion of the cluster infrastructure.
+	DeletingCondition clusterv1.ConditionType = "Deleting"
+	// WaitingForMachinesDeletionReason used while CoxMachines of the cluster still exist
+	WaitingForMachinesDeletionReason = "WaitingForMachinesDeletion"
+	// DeletingLoadBalancersReason used while the load balancers of the cluster are being deleted
+	DeletingLoadBalancersReason = "DeletingLoadBalancers"
+	// DeletingOrphanedWorkloadsReason used while remaining workloads of the cluster are being deleted
+	DeletingOrphanedWorkloadsReason = "DeletingOrphanedWorkloads"
+	// DeletingNetworkReason used while the VPC and subnets created for the cluster are being deleted
+	DeletingNetworkReason = "DeletingNetwork"
 )
 
 const (
@@ -78,11 +140,21 @@ type CoxClusterReconciler struct {
 	DefaultCredentials *scope.Credentials
 	Scheme             *runtime.Scheme
 	Recorder           record.EventRecorder
+	// RequeuePolicy determines when objects waiting on Cox Edge and ready
+	// objects are reconciled again.
+	RequeuePolicy RequeuePolicy
+	// ClientFactory creates the Cox Edge clients, rate limited per set of
+	// credentials.
+	ClientFactory *coxedge.ClientFactory
+	// MaxConcurrentReconciles is the number of CoxClusters reconciled
+	// concurrently.
+	MaxConcurrentReconciles int
 }
 
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=coxclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=coxclusters/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -110,6 +182,12 @@ func (r *CoxClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 	if cluster == nil {
+		if !coxCluster.DeletionTimestamp.IsZero() && controllerutil.ContainsFinalizer(&coxCluster, coxv1.ClusterFinalizer) {
+			// Nothing has been created for a CoxCluster that never had an owner.
+			patch := client.MergeFrom(coxCluster.DeepCopy())
+			controllerutil.RemoveFinalizer(&coxCluster, coxv1.ClusterFinalizer)
+			return ctrl.Result{}, r.Patch(ctx, &coxCluster, patch)
+		}
 		log.Info("OwnerCluster is not set yet. Requeuing...")
 		return ctrl.Result{}, nil
 	}
@@ -126,6 +204,7 @@ func (r *CoxClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		Cluster:            cluster,
 		CoxCluster:         &coxCluster,
 		DefaultCredentials: r.DefaultCredentials,
+		ClientFactory:      r.ClientFactory,
 	})
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to create scope: %+v", err)
@@ -138,7 +217,7 @@ func (r *CoxClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}()
 
 	// Handle deleted clusters
-	if !cluster.DeletionTimestamp.IsZero() {
+	if !cluster.DeletionTimestamp.IsZero() || !coxCluster.DeletionTimestamp.IsZero() {
 		return r.reconcileDelete(ctx, clusterScope)
 	}
 	return r.reconcileNormal(ctx, clusterScope)
@@ -148,19 +227,40 @@ func (r *CoxClusterReconciler) reconcileNormal(ctx context.Context, clusterScope
 	log := ctrl.LoggerFrom(ctx)
 	coxCluster := clusterScope.CoxCluster
 	controllerutil.AddFinalizer(coxCluster, coxv1.ClusterFinalizer)
-	conditions.MarkUnknown(coxCluster, CoxClusterReadyCondition, "", "")
+	// The condition is only initialized, so that it keeps the time the
+	// cluster started waiting, which the requeue interval backs off from.
+	if !conditions.Has(coxCluster, CoxClusterReadyCondition) {
+		conditions.MarkUnknown(coxCluster, CoxClusterReadyCondition, "", "")
+	}
+	defaultControlPlaneLoadBalancerType(coxCluster)
+	coxCluster.Status.FailureDomains = failureDomains(coxCluster)
+	if coxCluster.Spec.Credentials != nil && len(coxCluster.Spec.Credentials.Name) > 0 {
+		if err := scope.EnsureCredentialsMoveLabel(ctx, r.Client, coxCluster.Namespace, coxCluster.Spec.Credentials.Name); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	ready, err := r.reconcileNetwork(ctx, clusterScope)
+	if err != nil {
+		conditions.MarkFalse(coxCluster, NetworkReadyCondition, NetworkReconcileFailedReason, clusterv1.ConditionSeverityError, err.Error())
+		return ctrl.Result{}, err
+	}
+	if !ready {
+		conditions.MarkFalse(coxCluster, CoxClusterReadyCondition, conditions.GetReason(coxCluster, NetworkReadyCondition), clusterv1.ConditionSeverityInfo, "Network is not ready yet")
+		return r.RequeuePolicy.WaitingOn(coxCluster, NetworkReadyCondition), nil
+	}
 
-	// Hacky way to retrieve the control plane endpoints from the machines
+	// Retrieve the load balancer backends from the machines of the cluster.
 	var apiserverAddresses []string
 	var workerAddresses []string
+	var controlPlaneAddresses []string
 	coxMachines := &coxv1.CoxMachineList{}
-	err := r.Client.List(ctx, coxMachines)
+	err = r.Client.List(ctx, coxMachines, client.InNamespace(clusterScope.Namespace()), client.MatchingLabels{clusterv1.ClusterLabelName: clusterScope.Name()})
 	if err != nil {
-		conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, MachineListFailedReason, clusterv1.ConditionSeverityInfo, err.Error())
+		conditions.MarkFalse(coxCluster, CoxClusterReadyCondition, MachineListFailedReason, clusterv1.ConditionSeverityInfo, err.Error())
 		return ctrl.Result{}, err
 	}
 	for _, coxMachine := range coxMachines.Items {
-		if coxMachine.Labels[clusterv1.ClusterLabelName] != clusterScope.Name() {
+		if !isLoadBalancerBackend(&coxMachine) {
 			continue
 		}
 
@@ -168,16 +268,18 @@ func (r *CoxClusterReconciler) reconcileNormal(ctx context.Context, clusterScope
 			continue
 		}
 
-		for _, addr := range coxMachine.Status.Addresses {
-			if addr.Type != corev1.NodeExternalIP {
-				continue
-			}
-			apiserverAddresses = append(apiserverAddresses, fmt.Sprintf("%s:%d", addr.Address, defaultKubeApiserverPort))
-			break
+		// Machines without a public IP are reached through the VPC.
+		address := machineAddress(&coxMachine, corev1.NodeExternalIP)
+		if len(address) == 0 {
+			address = machineAddress(&coxMachine, corev1.NodeInternalIP)
+		}
+		if len(address) > 0 {
+			controlPlaneAddresses = append(controlPlaneAddresses, address)
+			apiserverAddresses = append(apiserverAddresses, net.JoinHostPort(address, strconv.Itoa(defaultKubeApiserverPort)))
 		}
 	}
 	for _, coxMachine := range coxMachines.Items {
-		if coxMachine.Labels[clusterv1.ClusterLabelName] != clusterScope.Name() {
+		if !isLoadBalancerBackend(&coxMachine) {
 			continue
 		}
 
@@ -185,30 +287,16 @@ func (r *CoxClusterReconciler) reconcileNormal(ctx context.Context, clusterScope
 			continue
 		}
 
-		workerLBSpecPorts := coxCluster.Spec.WorkersLoadBalancer.Ports
-		for _, addr := range coxMachine.Status.Addresses {
-			if addr.Type != corev1.NodeInternalIP {
-				continue
-			}
-			if len(workerLBSpecPorts) == 0 {
-				workerAddresses = append(workerAddresses, fmt.Sprintf("%s:%d", addr.Address, defaultWorkerLBPort))
-			} else {
-				for _, port := range workerLBSpecPorts {
-					workerAddresses = append(workerAddresses, fmt.Sprintf("%s:%s", addr.Address, port))
-				}
+		if address := machineAddress(&coxMachine, corev1.NodeInternalIP); len(address) > 0 {
+			for _, port := range workerBackendPorts(coxCluster) {
+				workerAddresses = append(workerAddresses, net.JoinHostPort(address, port))
 			}
-
-			break
 		}
 	}
-	if len(apiserverAddresses) == 0 {
-		// Needs to be set to some value
-		apiserverAddresses = []string{defaultBackend}
-	}
-	if len(workerAddresses) == 0 {
-		// Needs to be set to some value
-		workerAddresses = []string{defaultBackend}
-	}
+	sort.Strings(controlPlaneAddresses)
+	sort.Strings(apiserverAddresses)
+	sort.Strings(workerAddresses)
+	coxCluster.Status.ControlPlaneAddresses = controlPlaneAddresses
 
 	var clusterPort = coxCluster.Spec.ControlPlaneLoadBalancer.Ports
 	var clusterPorts []string
@@ -227,173 +315,369 @@ func (r *CoxClusterReconciler) reconcileNormal(ctx context.Context, clusterScope
 	var workerLBPorts []string
 	workerLBPorts = append(workerLBPorts, fmt.Sprint(defaultWorkerLBPort))
 
-	var clusterLBSize = clusterScope.CoxCluster.Spec.ControlPlaneLoadBalancer.Size
-	if len(clusterLBSize) == 0 {
-		clusterLBSize = "1"
-	}
-
-	var workersLBSize = clusterScope.CoxCluster.Spec.WorkersLoadBalancer.Size
-	if len(workersLBSize) == 0 {
-		workersLBSize = "1"
-	}
-
-	// Ensure that the loadBalancer is created
-	lbClient := coxedge.NewLoadBalancerHelper(clusterScope.CoxClient)
-	workerLbClient := coxedge.NewLoadBalancerHelper(clusterScope.CoxClient)
-	loadBalancerSpec := coxedge.LoadBalancerSpec{
-		Name:      genClusterLoadBalancerName(clusterScope),
-		Image:     loadBalancerImage,
-		Port:      clusterPorts,
-		Backends:  apiserverAddresses,
-		POP:       clusterScope.CoxCluster.Spec.ControlPlaneLoadBalancer.POP,
-		Instances: clusterLBSize,
-	}
-	workerLoadBalancerSpec := coxedge.LoadBalancerSpec{
-		Name:      genWorkerLoadBalancerName(clusterScope),
-		Image:     loadBalancerImage,
-		Port:      workerLBPorts,
-		Backends:  workerAddresses,
-		POP:       clusterScope.CoxCluster.Spec.WorkersLoadBalancer.POP,
-		Instances: workersLBSize,
-	}
-	existingLoadBalancer, err := lbClient.GetLoadBalancer(ctx, loadBalancerSpec.Name)
-	existingworkerLoadBalancer, err1 := workerLbClient.GetLoadBalancer(ctx, workerLoadBalancerSpec.Name)
-	if err != nil && err1 != nil {
-		if err != coxedge.ErrWorkloadNotFound {
-			conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, LoadBalancerNotFoundReason, clusterv1.ConditionSeverityInfo, err.Error())
-			return ctrl.Result{}, err
-		}
-		if err1 != coxedge.ErrWorkloadNotFound {
-			conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, LoadBalancerNotFoundReason, clusterv1.ConditionSeverityInfo, err.Error())
-			return ctrl.Result{}, err
+	// Ensure that the loadBalancers are created
+	var controlPlaneLoadBalancer *coxv1.CoxLoadBalancer
+	if coxCluster.Spec.ControlPlaneLoadBalancer.Type != coxv1.ExternalLoadBalancerType {
+		lbSpec := coxCluster.Spec.ControlPlaneLoadBalancer
+		// Without DNS, replacing the load balancer would change the control plane endpoint.
+		replacementStrategy := coxv1.NoReplacementStrategy
+		gracePeriod := defaultRetiredLoadBalancerGracePeriod
+		if coxCluster.Spec.DNS != nil {
+			replacementStrategy = coxv1.BlueGreenReplacementStrategy
+			gracePeriod += time.Duration(coxCluster.Spec.DNS.TTL) * time.Second
 		}
-		err = lbClient.CreateLoadBalancer(ctx, &loadBalancerSpec)
-		err1 = workerLbClient.CreateLoadBalancer(ctx, &workerLoadBalancerSpec)
+		controlPlaneLoadBalancer, err = r.reconcileClusterLoadBalancer(ctx, clusterScope, genControlPlaneCoxLoadBalancerName(clusterScope), coxv1.CoxLoadBalancerResourceSpec{
+			Credentials:           coxCluster.Spec.Credentials,
+			WorkloadName:          genClusterLoadBalancerName(clusterScope),
+			Image:                 loadBalancerImage,
+			Ports:                 clusterPorts,
+			Backends:              apiserverAddresses,
+			POP:                   lbSpec.POP,
+			Size:                  lbSpec.Size,
+			AutoScaling:           lbSpec.AutoScaling,
+			Specs:                 lbSpec.Specs,
+			Anycast:               lbSpec.Anycast,
+			NetworkInterfaces:     loadBalancerNetworkInterfaces(clusterScope.Cluster, coxCluster.Spec.Network),
+			ReplacementStrategy:   replacementStrategy,
+			RetirementGracePeriod: &metav1.Duration{Duration: gracePeriod},
+		}, &coxCluster.Status.ControlPlaneLoadBalancer)
 		if err != nil {
-			r.Recorder.Eventf(coxCluster, corev1.EventTypeNormal, "CreatingLoadBalancerFailed", "Failed to create loadbalancer for cluster '%s`:`%s`", coxCluster.Name, coxCluster.UID, err)
-			conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, LoadBalancerCreateFailedReason, clusterv1.ConditionSeverityInfo, err.Error())
+			conditions.MarkFalse(coxCluster, ControlPlaneLoadBalancerReadyCondition, LoadBalancerCreateFailedReason, clusterv1.ConditionSeverityError, err.Error())
 			return ctrl.Result{}, err
 		}
-		if err1 != nil {
-			r.Recorder.Eventf(coxCluster, corev1.EventTypeNormal, "CreatingLoadBalancerFailed", "Failed to create worker loadbalancer for cluster '%s`:`%s`", coxCluster.Name, coxCluster.UID, err)
-			conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, LoadBalancerCreateFailedReason, clusterv1.ConditionSeverityInfo, err.Error())
+		mirrorCondition(coxCluster, ControlPlaneLoadBalancerReadyCondition, controlPlaneLoadBalancer, LoadBalancerReadyCondition)
+		mirrorCondition(coxCluster, ControlPlaneLoadBalancerUpToDateCondition, controlPlaneLoadBalancer, LoadBalancerUpToDateCondition)
+		if conditions.GetReason(coxCluster, ControlPlaneLoadBalancerUpToDateCondition) == LoadBalancerRolloutBlockedReason {
+			conditions.MarkFalse(coxCluster, ControlPlaneLoadBalancerUpToDateCondition, LoadBalancerRolloutBlockedReason, clusterv1.ConditionSeverityWarning,
+				"Changing the image, POPs or specs of the control plane load balancer requires spec.dns to be set, because the replacement has a different address")
+		}
+	}
+
+	var workersLoadBalancer *coxv1.CoxLoadBalancer
+	if coxCluster.Spec.WorkersLoadBalancer.Type != coxv1.ExternalLoadBalancerType {
+		workersLBSpec := coxCluster.Spec.WorkersLoadBalancer
+		workersLoadBalancer, err = r.reconcileClusterLoadBalancer(ctx, clusterScope, genWorkersCoxLoadBalancerName(clusterScope), coxv1.CoxLoadBalancerResourceSpec{
+			Credentials:         coxCluster.Spec.Credentials,
+			WorkloadName:        genWorkerLoadBalancerName(clusterScope),
+			Image:               loadBalancerImage,
+			Ports:               workerLBPorts,
+			Backends:            workerAddresses,
+			POP:                 workersLBSpec.POP,
+			Size:                workersLBSpec.Size,
+			AutoScaling:         workersLBSpec.AutoScaling,
+			Specs:               workersLBSpec.Specs,
+			Anycast:             workersLBSpec.Anycast,
+			NetworkInterfaces:   loadBalancerNetworkInterfaces(clusterScope.Cluster, coxCluster.Spec.Network),
+			ReplacementStrategy: coxv1.BlueGreenReplacementStrategy,
+		}, &coxCluster.Status.WorkersLoadBalancer)
+		if err != nil {
+			conditions.MarkFalse(coxCluster, WorkersLoadBalancerReadyCondition, LoadBalancerCreateFailedReason, clusterv1.ConditionSeverityError, err.Error())
 			return ctrl.Result{}, err
 		}
-		log.Info("Created LoadBalancer deployment", "spec", loadBalancerSpec)
-		log.Info("Created worker LoadBalancer deployment", "spec", workerLoadBalancerSpec)
-		r.Recorder.Eventf(coxCluster, corev1.EventTypeNormal, "CreatedLoadBalancer", "Created LoadBalancer for cluster '%s`:`%s`", coxCluster.Name, coxCluster.UID)
-		r.Recorder.Eventf(coxCluster, corev1.EventTypeNormal, "CreatedLoadBalancer", "Created Worker LoadBalancer for cluster '%s`:`%s`", coxCluster.Name, coxCluster.UID)
-		conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, LoadBalancerCreateFailedReason, clusterv1.ConditionSeverityInfo, "Creating LoadBalancer deployment")
-		return ctrl.Result{Requeue: true}, nil
-	}
-
-	// Ignore the name of the existing one because it might have been shortened.
-	loadBalancerSpec.Name = existingLoadBalancer.Spec.Name
-	//Sort Backends Addresses before running DeepEqual, else objects will return false resulting in LB getting restarted every few seconds in MultiMaster Mode
-	sort.Strings(loadBalancerSpec.Backends)
-	sort.Strings(existingLoadBalancer.Spec.Backends)
-	if !reflect.DeepEqual(existingLoadBalancer.Spec.Backends, loadBalancerSpec.Backends) {
-		existingLoadBalancer.Status = coxedge.LoadBalancerStatus{}
-		err = lbClient.UpdateLoadBalancer(ctx, &loadBalancerSpec)
+		mirrorCondition(coxCluster, WorkersLoadBalancerReadyCondition, workersLoadBalancer, LoadBalancerReadyCondition)
+		mirrorCondition(coxCluster, WorkersLoadBalancerUpToDateCondition, workersLoadBalancer, LoadBalancerUpToDateCondition)
+	} else {
+		// The workers are exposed outside of the provider, so a workers load
+		// balancer deployed before the type was switched is deleted.
+		deleting, err := r.deleteWorkersLoadBalancer(ctx, clusterScope)
 		if err != nil {
-			conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, LoadBalancerUpdateFailedReason, clusterv1.ConditionSeverityInfo, err.Error())
 			return ctrl.Result{}, err
 		}
-		log.Info("Updated LoadBalancer deployment", "old", existingLoadBalancer.Spec, "new", loadBalancerSpec)
+		if deleting {
+			conditions.MarkFalse(coxCluster, WorkersLoadBalancerReadyCondition, DeletingLoadBalancerReason, clusterv1.ConditionSeverityInfo, "Waiting for the workers load balancer to be deleted")
+			return r.RequeuePolicy.WaitingOn(coxCluster, WorkersLoadBalancerReadyCondition), nil
+		}
+		coxCluster.Status.WorkersLoadBalancer = coxv1.CoxLoadBalancerStatus{}
+		conditions.MarkTrue(coxCluster, WorkersLoadBalancerReadyCondition)
+		conditions.Delete(coxCluster, WorkersLoadBalancerUpToDateCondition)
 	}
 
-	workerLoadBalancerSpec.Name = existingworkerLoadBalancer.Spec.Name
-	//Sort Backends Addresses before running DeepEqual, else objects will return false resulting in WorkerLB getting restarted every few seconds
-	sort.Strings(workerLoadBalancerSpec.Backends)
-	sort.Strings(existingworkerLoadBalancer.Spec.Backends)
-	if !reflect.DeepEqual(existingworkerLoadBalancer.Spec.Backends, workerLoadBalancerSpec.Backends) {
-		existingworkerLoadBalancer.Status = coxedge.LoadBalancerStatus{}
-		err = workerLbClient.UpdateLoadBalancer(ctx, &workerLoadBalancerSpec)
+	if coxCluster.Spec.ControlPlaneLoadBalancer.Type == coxv1.ExternalLoadBalancerType {
+		// The endpoint is managed outside of the provider; it only needs to be present.
+		if !coxCluster.Spec.ControlPlaneEndpoint.IsValid() {
+			log.Info("External control plane endpoint has not been set yet.")
+			conditions.MarkFalse(coxCluster, ControlPlaneLoadBalancerReadyCondition, ControlPlaneEndpointNotSetReason, clusterv1.ConditionSeverityWarning, "ControlPlaneEndpoint must be set when using an External control plane load balancer")
+			conditions.MarkFalse(coxCluster, CoxClusterReadyCondition, ControlPlaneEndpointNotSetReason, clusterv1.ConditionSeverityWarning, "ControlPlaneEndpoint must be set when using an External control plane load balancer")
+			return ctrl.Result{}, nil
+		}
+		conditions.MarkTrue(coxCluster, ControlPlaneLoadBalancerReadyCondition)
+	} else {
+		if !controlPlaneLoadBalancer.Status.Ready {
+			log.Info("LoadBalancer is not ready yet.")
+			conditions.MarkFalse(coxCluster, CoxClusterReadyCondition, LoadBalancerNotReadyReason, clusterv1.ConditionSeverityInfo, "LoadBalancer is not ready yet")
+			return r.RequeuePolicy.WaitingOn(coxCluster, CoxClusterReadyCondition), nil
+		}
+
+		// Set the controlPlaneRef
+		port, err := strconv.Atoi(controlPlaneLoadBalancer.Spec.Ports[0])
 		if err != nil {
-			conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, LoadBalancerUpdateFailedReason, clusterv1.ConditionSeverityInfo, err.Error())
 			return ctrl.Result{}, err
 		}
-		log.Info("Updated Worker LoadBalancer deployment", "old", existingworkerLoadBalancer.Spec, "new", workerLoadBalancerSpec)
-	}
-
-	if existingLoadBalancer != nil && len(existingLoadBalancer.Status.PublicIP) == 0 {
-		log.Info("LoadBalancer is not ready yet.")
-		conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, LoadBalancerNotReadyReason, clusterv1.ConditionSeverityInfo, "LoadBalancer is not ready yet")
-		return ctrl.Result{
-			RequeueAfter: 10 * time.Second,
-		}, nil
+		host := controlPlaneLoadBalancer.Status.PublicIP
+		if coxCluster.Spec.DNS != nil {
+			err = r.reconcileDNSRecord(ctx, clusterScope, controlPlaneLoadBalancer.Status.Addresses)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			host = coxCluster.Spec.DNS.FQDN
+		}
+		clusterScope.CoxCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
+			Host: host,
+			Port: int32(port),
+		}
 	}
-
-	// if existingworkerLoadBalancer != nil && len(existingworkerLoadBalancer.Status.PublicIP) == 0 {
-	// 	log.Info("Worker LoadBalancer is not ready yet.")
-	// 	conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, LoadBalancerNotReadyReason, clusterv1.ConditionSeverityInfo, "Worker LoadBalancer is not ready yet")
-	// 	return ctrl.Result{
-	// 		RequeueAfter: 10 * time.Second,
-	// 	}, nil
-	// }
-
-	// Set the controlPlaneRef
-	port, err := strconv.Atoi(existingLoadBalancer.Spec.Port[0])
-	if err != nil {
+	if err := r.reconcileNetworkPolicy(ctx, clusterScope, coxMachines.Items, controlPlaneLoadBalancer, workersLoadBalancer); err != nil {
+		conditions.MarkFalse(coxCluster, NetworkPolicyReadyCondition, NetworkPolicyReconcileFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 		return ctrl.Result{}, err
 	}
-	clusterScope.CoxCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
-		Host: existingLoadBalancer.Status.PublicIP,
-		Port: int32(port),
+	if workersLoadBalancer != nil && len(workersLoadBalancer.Status.WorkloadID) == 0 {
+		conditions.MarkFalse(coxCluster, CoxClusterReadyCondition, WaitingLoadBalancerReason, clusterv1.ConditionSeverityInfo, "Creating worker LoadBalancer deployment")
+		return r.RequeuePolicy.WaitingOn(coxCluster, CoxClusterReadyCondition), nil
 	}
+
 	clusterScope.CoxCluster.Status.Ready = true
-	clusterScope.CoxCluster.Status.ControlPlaneLoadBalancer.PublicIP = existingLoadBalancer.Status.PublicIP
 
 	// Hack: requeue as long as the load balancer does not yet have an appropriate backend.
-	if apiserverAddresses[0] == defaultBackend {
+	if coxCluster.Spec.ControlPlaneLoadBalancer.Type != coxv1.ExternalLoadBalancerType && len(apiserverAddresses) == 0 {
 		log.Info("LoadBalancer does not yet have a valid apiserver to use as backend.")
-		conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, LoadBalancerInvalidBackendReason, clusterv1.ConditionSeverityInfo, "LoadBalancer does not yet have a valid apiserver to use as backend.")
-		return ctrl.Result{
-			RequeueAfter: 10 * time.Second,
-		}, nil
+		conditions.MarkFalse(coxCluster, CoxClusterReadyCondition, LoadBalancerInvalidBackendReason, clusterv1.ConditionSeverityInfo, "LoadBalancer does not yet have a valid apiserver to use as backend.")
+		return r.RequeuePolicy.WaitingOn(coxCluster, CoxClusterReadyCondition), nil
 	}
 
-	if workerAddresses[0] == defaultBackend {
+	if workersLoadBalancer != nil && len(workerAddresses) == 0 {
 		log.Info("Worker LoadBalancer does not yet have a valid worker ip address assigned")
-		conditions.MarkFalse(clusterScope.Cluster, CoxClusterReadyCondition, LoadBalancerInvalidBackendReason, clusterv1.ConditionSeverityInfo, "Worker LoadBalancer does not yet have a valid worker ip address assigned.")
-		return ctrl.Result{
-			RequeueAfter: 10 * time.Second,
-		}, nil
+		conditions.MarkFalse(coxCluster, CoxClusterReadyCondition, LoadBalancerInvalidBackendReason, clusterv1.ConditionSeverityInfo, "Worker LoadBalancer does not yet have a valid worker ip address assigned.")
+		return r.RequeuePolicy.WaitingOn(coxCluster, CoxClusterReadyCondition), nil
 	}
 
 	log.Info("Cluster reconciled.")
-	conditions.MarkTrue(clusterScope.Cluster, CoxClusterReadyCondition)
-	return ctrl.Result{
-		// Requeue to make sure that the controller reconciles drift on the Cox Edge side.
-		RequeueAfter: 5 * time.Minute,
-	}, nil
+	conditions.MarkTrue(coxCluster, CoxClusterReadyCondition)
+	// Requeue to make sure that the controller reconciles drift on the Cox Edge side.
+	return r.RequeuePolicy.Synced(), nil
+}
+
+// reconcileClusterLoadBalancer ensures that the CoxLoadBalancer with the
+// given name exists, is owned by the CoxCluster and matches the desired spec.
+// The observed state of the load balancer is copied to status.
+func (r *CoxClusterReconciler) reconcileClusterLoadBalancer(ctx context.Context, clusterScope *scope.ClusterScope, name string, desired coxv1.CoxLoadBalancerResourceSpec, status *coxv1.CoxLoadBalancerStatus) (*coxv1.CoxLoadBalancer, error) {
+	log := ctrl.LoggerFrom(ctx)
+	coxCluster := clusterScope.CoxCluster
+	if len(status.Name) > 0 {
+		// Adopt the workload that was managed by the CoxCluster itself before
+		// load balancers had their own resource.
+		desired.WorkloadName = status.Name
+	}
+
+	coxLoadBalancer := &coxv1.CoxLoadBalancer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: coxCluster.Namespace,
+		},
+	}
+	result, err := controllerutil.CreateOrPatch(ctx, r.Client, coxLoadBalancer, func() error {
+		// The workload name can not be changed without losing track of the workload.
+		if len(coxLoadBalancer.Spec.WorkloadName) > 0 {
+			desired.WorkloadName = coxLoadBalancer.Spec.WorkloadName
+		}
+		coxLoadBalancer.Spec = desired
+		if coxLoadBalancer.Labels == nil {
+			coxLoadBalancer.Labels = map[string]string{}
+		}
+		coxLoadBalancer.Labels[clusterv1.ClusterLabelName] = clusterScope.Name()
+		return controllerutil.SetControllerReference(coxCluster, coxLoadBalancer, r.Scheme)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile CoxLoadBalancer %s: %w", name, err)
+	}
+	if result == controllerutil.OperationResultCreated {
+		log.Info("Created CoxLoadBalancer", "name", name)
+		r.Recorder.Eventf(coxCluster, corev1.EventTypeNormal, "CreatedLoadBalancer", "Created CoxLoadBalancer %s for cluster '%s`:`%s`", name, coxCluster.Name, coxCluster.UID)
+	}
+	*status = coxLoadBalancer.Status.CoxLoadBalancerStatus
+	return coxLoadBalancer, nil
+}
+
+// deleteWorkersLoadBalancer deletes the workers CoxLoadBalancer of the
+// cluster, if it is controlled by the CoxCluster, and returns whether it still
+// exists. The CoxLoadBalancer controller deletes its workload.
+func (r *CoxClusterReconciler) deleteWorkersLoadBalancer(ctx context.Context, clusterScope *scope.ClusterScope) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	coxCluster := clusterScope.CoxCluster
+	name := genWorkersCoxLoadBalancerName(clusterScope)
+	coxLoadBalancer := &coxv1.CoxLoadBalancer{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: coxCluster.Namespace, Name: name}, coxLoadBalancer)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !metav1.IsControlledBy(coxLoadBalancer, coxCluster) {
+		return false, nil
+	}
+	if coxLoadBalancer.DeletionTimestamp.IsZero() {
+		if err := r.Delete(ctx, coxLoadBalancer); err != nil && !apierrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to delete CoxLoadBalancer %s: %w", name, err)
+		}
+		log.Info("Deleted CoxLoadBalancer of the External workers load balancer", "name", name)
+		r.Recorder.Eventf(coxCluster, corev1.EventTypeNormal, "DeletedLoadBalancer", "Deleted CoxLoadBalancer %s, since the workers load balancer is External", name)
+	}
+	return true, nil
+}
+
+// mirrorCondition copies a condition of a CoxLoadBalancer to the CoxCluster
+// under a different type.
+func mirrorCondition(to conditions.Setter, toType clusterv1.ConditionType, from conditions.Getter, fromType clusterv1.ConditionType) {
+	condition := conditions.Get(from, fromType)
+	if condition == nil {
+		conditions.MarkFalse(to, toType, WaitingLoadBalancerReason, clusterv1.ConditionSeverityInfo, "Waiting for the CoxLoadBalancer to be reconciled")
+		return
+	}
+	condition = condition.DeepCopy()
+	condition.Type = toType
+	conditions.Set(to, condition)
+}
+
+// reconcileDNSRecord points the DNS record of the control plane endpoint to
+// the given load balancer addresses.
+func (r *CoxClusterReconciler) reconcileDNSRecord(ctx context.Context, clusterScope *scope.ClusterScope, addresses []string) error {
+	log := ctrl.LoggerFrom(ctx)
+	dnsSpec := clusterScope.CoxCluster.Spec.DNS
+	provider, err := clusterScope.DNSProvider(ctx)
+	if err != nil {
+		conditions.MarkFalse(clusterScope.CoxCluster, ControlPlaneLoadBalancerReadyCondition, DNSRecordUpdateFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return err
+	}
+
+	dnsCtx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+	err = provider.EnsureRecord(dnsCtx, dnsSpec.FQDN, addresses, uint32(dnsSpec.TTL))
+	if err != nil {
+		r.Recorder.Eventf(clusterScope.CoxCluster, corev1.EventTypeWarning, "UpdatingDNSRecordFailed", "Failed to point %s to %v: %v", dnsSpec.FQDN, addresses, err)
+		conditions.MarkFalse(clusterScope.CoxCluster, ControlPlaneLoadBalancerReadyCondition, DNSRecordUpdateFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return fmt.Errorf("failed to update DNS record %s: %w", dnsSpec.FQDN, err)
+	}
+	log.V(1).Info("Reconciled DNS record", "fqdn", dnsSpec.FQDN, "addresses", addresses)
+	return nil
 }
 
 func (r *CoxClusterReconciler) reconcileDelete(ctx context.Context, clusterScope *scope.ClusterScope) (ctrl.Result, error) {
-	loadBalancerName := genClusterLoadBalancerName(clusterScope)
-	workerLoadBalancerName := genWorkerLoadBalancerName(clusterScope)
-	lbClient := coxedge.NewLoadBalancerHelper(clusterScope.CoxClient)
-	workerLbClient := coxedge.NewLoadBalancerHelper(clusterScope.CoxClient)
-	err := lbClient.DeleteLoadBalancer(ctx, loadBalancerName)
-	err1 := workerLbClient.DeleteLoadBalancer(ctx, workerLoadBalancerName)
+	log := ctrl.LoggerFrom(ctx)
+	coxCluster := clusterScope.CoxCluster
+	conditions.MarkFalse(coxCluster, CoxClusterReadyCondition, clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
+
+	// The machines are routed to by the load balancers until they are gone,
+	// so the load balancers are only deleted after the machines.
+	coxMachines := &coxv1.CoxMachineList{}
+	err := r.List(ctx, coxMachines, client.InNamespace(clusterScope.Namespace()), client.MatchingLabels{clusterv1.ClusterLabelName: clusterScope.Name()})
 	if err != nil {
-		r.Recorder.Eventf(clusterScope.Cluster, corev1.EventTypeNormal, "DeletingLoadBalancerFailed", "Failed to delete loadbalancer for cluster '%s`:`%s`", clusterScope.Cluster.Name, clusterScope.Cluster.UID, err)
 		return ctrl.Result{}, err
 	}
-	if err1 != nil {
-		r.Recorder.Eventf(clusterScope.Cluster, corev1.EventTypeNormal, "DeletingLoadBalancerFailed", "Failed to delete worker loadbalancer for cluster '%s`:`%s`", clusterScope.Cluster.Name, clusterScope.Cluster.UID, err)
+	if len(coxMachines.Items) > 0 {
+		r.markDeleting(coxCluster, WaitingForMachinesDeletionReason, "Waiting for %d CoxMachines to be deleted", len(coxMachines.Items))
+		log.Info("Waiting for CoxMachines to be deleted", "count", len(coxMachines.Items))
+		return r.RequeuePolicy.Waiting(coxCluster.DeletionTimestamp.Time), nil
+	}
+
+	if coxCluster.Spec.ControlPlaneLoadBalancer.Type != coxv1.ExternalLoadBalancerType {
+		if dnsSpec := coxCluster.Spec.DNS; dnsSpec != nil {
+			provider, err := clusterScope.DNSProvider(ctx)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			dnsCtx, cancel := context.WithTimeout(ctx, dnsTimeout)
+			defer cancel()
+			if err := provider.DeleteRecord(dnsCtx, dnsSpec.FQDN); err != nil {
+				r.Recorder.Eventf(coxCluster, corev1.EventTypeWarning, "DeletingDNSRecordFailed", "Failed to delete DNS record %s: %v", dnsSpec.FQDN, err)
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	// The CoxLoadBalancer controller deletes the workloads of the load balancers.
+	var deleting []string
+	for _, name := range []string{genControlPlaneCoxLoadBalancerName(clusterScope), genWorkersCoxLoadBalancerName(clusterScope)} {
+		coxLoadBalancer := &coxv1.CoxLoadBalancer{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: coxCluster.Namespace, Name: name}, coxLoadBalancer)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		deleting = append(deleting, name)
+		if !coxLoadBalancer.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.Delete(ctx, coxLoadBalancer); err != nil && !apierrors.IsNotFound(err) {
+			r.Recorder.Eventf(clusterScope.Cluster, corev1.EventTypeNormal, "DeletingLoadBalancerFailed", "Failed to delete loadbalancer %s for cluster '%s`:`%s`", name, clusterScope.Cluster.Name, clusterScope.Cluster.UID, err)
+			return ctrl.Result{}, err
+		}
+	}
+	if len(deleting) > 0 {
+		r.markDeleting(coxCluster, DeletingLoadBalancersReason, "Waiting for load balancers %s to be deleted", strings.Join(deleting, ", "))
+		log.Info("Waiting for load balancers to be deleted", "loadBalancers", deleting)
+		return r.RequeuePolicy.Waiting(coxCluster.DeletionTimestamp.Time), nil
+	}
+
+	// Sweep load balancer workloads that are no longer tracked by a
+	// CoxLoadBalancer, such as replacements whose status was lost.
+	r.markDeleting(coxCluster, DeletingOrphanedWorkloadsReason, "Deleting remaining workloads of the cluster")
+	orphans, err := coxedge.NewLoadBalancerHelper(clusterScope.CoxClient).DeleteOwnedLoadBalancers(ctx, clusterLoadBalancerOwner(coxCluster.Namespace, coxCluster.Name))
+	if err != nil {
+		r.Recorder.Eventf(coxCluster, corev1.EventTypeWarning, "DeletingOrphanedWorkloadsFailed", "Failed to delete remaining workloads: %v", err)
 		return ctrl.Result{}, err
 	}
+	if len(orphans) > 0 {
+		log.Info("Deleted orphaned load balancer workloads", "workloads", orphans)
+		r.Recorder.Eventf(coxCluster, corev1.EventTypeNormal, "DeletedOrphanedWorkloads", "Deleted remaining workloads %s", strings.Join(orphans, ", "))
+	}
+
 	r.Recorder.Eventf(clusterScope.Cluster, corev1.EventTypeNormal, "DeletedLoadBalancer", "Deleted control plane and worker loadbalancers for cluster '%s`:`%s`", clusterScope.Cluster.Name, clusterScope.Cluster.UID)
-	controllerutil.RemoveFinalizer(clusterScope.CoxCluster, coxv1.ClusterFinalizer)
+
+	// The VPC can only be deleted once none of the workloads use it anymore.
+	if coxCluster.Spec.Network != nil {
+		remaining, err := r.deleteNetwork(ctx, clusterScope)
+		if err != nil {
+			r.Recorder.Eventf(coxCluster, corev1.EventTypeWarning, "DeletingNetworkFailed", "Failed to delete the network of the cluster: %v", err)
+			return ctrl.Result{}, err
+		}
+		if len(remaining) > 0 {
+			r.markDeleting(coxCluster, DeletingNetworkReason, "Waiting for %s to be deleted", strings.Join(remaining, ", "))
+			return r.RequeuePolicy.Waiting(coxCluster.DeletionTimestamp.Time), nil
+		}
+	}
+	controllerutil.RemoveFinalizer(coxCluster, coxv1.ClusterFinalizer)
 	return ctrl.Result{}, nil
 }
 
+// markDeleting reports the current step of the deletion of the cluster
+// infrastructure, emitting an event whenever a new step is started.
+func (r *CoxClusterReconciler) markDeleting(coxCluster *coxv1.CoxCluster, reason string, messageFormat string, messageArgs ...interface{}) {
+	message := fmt.Sprintf(messageFormat, messageArgs...)
+	if conditions.GetReason(coxCluster, DeletingCondition) != reason {
+		r.Recorder.Event(coxCluster, corev1.EventTypeNormal, reason, message)
+	}
+	conditions.Set(coxCluster, &clusterv1.Condition{
+		Type:    DeletingCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *CoxClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
 	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&coxv1.CoxCluster{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Owns(&coxv1.CoxLoadBalancer{}).
 		WithEventFilter(predicates.ResourceNotPaused(ctrl.LoggerFrom(ctx))). // don't queue reconcile if resource is paused
+		Watches(
+			&source.Kind{Type: &coxv1.CoxMachine{}},
+			handler.EnqueueRequestsFromMapFunc(r.CoxMachineToCoxCluster(ctx)),
+			builder.WithPredicates(coxMachineBackendsChanged()),
+		).
 		Build(r)
 	if err != nil {
 		return fmt.Errorf("error creating controller: %w", err)
@@ -411,6 +695,58 @@ func (r *CoxClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 	return nil
 }
 
+// CoxMachineToCoxCluster maps a CoxMachine to the CoxCluster of its cluster,
+// so that the load balancer backends follow machines being created and deleted.
+func (r *CoxClusterReconciler) CoxMachineToCoxCluster(ctx context.Context) handler.MapFunc {
+	log := ctrl.LoggerFrom(ctx)
+	return func(o client.Object) []ctrl.Request {
+		m, ok := o.(*coxv1.CoxMachine)
+		if !ok {
+			log.Error(fmt.Errorf("expected a CoxMachine but got a %T", o), "failed to get CoxCluster for CoxMachine")
+			return nil
+		}
+
+		clusterName, ok := m.Labels[clusterv1.ClusterLabelName]
+		if !ok {
+			return nil
+		}
+		cluster := &clusterv1.Cluster{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: clusterName}, cluster); err != nil {
+			if !apierrors.IsNotFound(err) {
+				log.Error(err, "failed to get owning cluster")
+			}
+			return nil
+		}
+		if cluster.Spec.InfrastructureRef == nil || cluster.Spec.InfrastructureRef.Kind != "CoxCluster" {
+			return nil
+		}
+
+		return []ctrl.Request{{
+			NamespacedName: client.ObjectKey{Namespace: m.Namespace, Name: cluster.Spec.InfrastructureRef.Name},
+		}}
+	}
+}
+
+// coxMachineBackendsChanged filters updates of CoxMachines down to those that
+// change the load balancer backends of their cluster.
+func coxMachineBackendsChanged() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldMachine, ok := e.ObjectOld.(*coxv1.CoxMachine)
+			if !ok {
+				return true
+			}
+			newMachine, ok := e.ObjectNew.(*coxv1.CoxMachine)
+			if !ok {
+				return true
+			}
+			return !reflect.DeepEqual(oldMachine.Status.Addresses, newMachine.Status.Addresses) ||
+				!reflect.DeepEqual(oldMachine.Labels, newMachine.Labels) ||
+				!oldMachine.DeletionTimestamp.Equal(newMachine.DeletionTimestamp)
+		},
+	}
+}
+
 func genClusterLoadBalancerName(scope *scope.ClusterScope) string {
 	name := scope.CoxCluster.Spec.ControlPlaneLoadBalancer.Name
 	if len(name) == 0 {
@@ -419,6 +755,14 @@ func genClusterLoadBalancerName(scope *scope.ClusterScope) string {
 	return fmt.Sprintf("lb-%s", name)
 }
 
+func genControlPlaneCoxLoadBalancerName(scope *scope.ClusterScope) string {
+	return fmt.Sprintf("%s-control-plane", scope.CoxCluster.Name)
+}
+
+func genWorkersCoxLoadBalancerName(scope *scope.ClusterScope) string {
+	return fmt.Sprintf("%s-workers", scope.CoxCluster.Name)
+}
+
 func genWorkerLoadBalancerName(scope *scope.ClusterScope) string {
 	name := scope.CoxCluster.Spec.ControlPlaneLoadBalancer.Name
 	if len(name) == 0 {
@@ -426,3 +770,466 @@ func genWorkerLoadBalancerName(scope *scope.ClusterScope) string {
 	}
 	return fmt.Sprintf("lbworker-%s", name)
 }
+
+// defaultControlPlaneLoadBalancerType persists the type of the control plane
+// load balancer. A ControlPlaneEndpoint that was provided before the provider
+// created a load balancer is treated as an external endpoint.
+func defaultControlPlaneLoadBalancerType(coxCluster *coxv1.CoxCluster) {
+	if len(coxCluster.Spec.ControlPlaneLoadBalancer.Type) > 0 {
+		return
+	}
+	if coxCluster.Spec.ControlPlaneEndpoint.IsValid() && len(coxCluster.Status.ControlPlaneLoadBalancer.PublicIP) == 0 {
+		coxCluster.Spec.ControlPlaneLoadBalancer.Type = coxv1.ExternalLoadBalancerType
+		return
+	}
+	coxCluster.Spec.ControlPlaneLoadBalancer.Type = coxv1.ManagedLoadBalancerType
+}
+
+// reconcileNetwork ensures that the VPC and subnets of the cluster exist,
+// creating the ones that define a CIDR. It returns whether the network is
+// ready to be used by the workloads of the cluster.
+func (r *CoxClusterReconciler) reconcileNetwork(ctx context.Context, clusterScope *scope.ClusterScope) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	coxCluster := clusterScope.CoxCluster
+	network := coxCluster.Spec.Network
+	if network == nil {
+		coxCluster.Status.Network = nil
+		conditions.Delete(coxCluster, NetworkReadyCondition)
+		return true, nil
+	}
+
+	vpc, err := clusterScope.CoxClient.GetVPCBySlug(network.VPC.Slug)
+	if errors.Is(err, coxedge.ErrVPCNotFound) {
+		if len(network.VPC.CIDR) == 0 {
+			conditions.MarkFalse(coxCluster, NetworkReadyCondition, NetworkNotFoundReason, clusterv1.ConditionSeverityError, "VPC %s does not exist", network.VPC.Slug)
+			return false, nil
+		}
+		log.Info("Creating VPC", "slug", network.VPC.Slug)
+		if _, err := clusterScope.CoxClient.CreateVPC(&coxedge.VPC{
+			Name: nameOrSlug(network.VPC.Name, network.VPC.Slug),
+			Slug: network.VPC.Slug,
+			CIDR: network.VPC.CIDR,
+		}); err != nil {
+			return false, fmt.Errorf("failed to create VPC %s: %w", network.VPC.Slug, err)
+		}
+		r.Recorder.Eventf(coxCluster, corev1.EventTypeNormal, "CreatedVPC", "Created VPC %s", network.VPC.Slug)
+		coxCluster.Status.Network = &coxv1.CoxNetworkStatus{CreatedVPC: true}
+		conditions.MarkFalse(coxCluster, NetworkReadyCondition, CreatingNetworkReason, clusterv1.ConditionSeverityInfo, "Creating VPC %s", network.VPC.Slug)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get VPC %s: %w", network.VPC.Slug, err)
+	}
+
+	// Whatever has been created for the cluster is remembered to be deleted with it.
+	status := &coxv1.CoxNetworkStatus{VPCID: vpc.ID}
+	if previous := coxCluster.Status.Network; previous != nil {
+		status.CreatedVPC = previous.CreatedVPC
+		status.CreatedSubnets = previous.CreatedSubnets
+	}
+	var creating []string
+	for _, subnetSpec := range network.Subnets {
+		subnet, err := clusterScope.CoxClient.GetSubnetBySlug(vpc.ID, subnetSpec.Slug)
+		if errors.Is(err, coxedge.ErrSubnetNotFound) {
+			if len(subnetSpec.CIDR) == 0 {
+				conditions.MarkFalse(coxCluster, NetworkReadyCondition, NetworkNotFoundReason, clusterv1.ConditionSeverityError, "Subnet %s does not exist in VPC %s", subnetSpec.Slug, network.VPC.Slug)
+				return false, nil
+			}
+			log.Info("Creating subnet", "slug", subnetSpec.Slug, "vpc", network.VPC.Slug)
+			if _, err := clusterScope.CoxClient.CreateSubnet(&coxedge.Subnet{
+				VPCID: vpc.ID,
+				Name:  nameOrSlug(subnetSpec.Name, subnetSpec.Slug),
+				Slug:  subnetSpec.Slug,
+				CIDR:  subnetSpec.CIDR,
+			}); err != nil {
+				return false, fmt.Errorf("failed to create subnet %s: %w", subnetSpec.Slug, err)
+			}
+			r.Recorder.Eventf(coxCluster, corev1.EventTypeNormal, "CreatedSubnet", "Created subnet %s in VPC %s", subnetSpec.Slug, network.VPC.Slug)
+			if !sets.NewString(status.CreatedSubnets...).Has(subnetSpec.Slug) {
+				status.CreatedSubnets = append(status.CreatedSubnets, subnetSpec.Slug)
+			}
+			creating = append(creating, subnetSpec.Slug)
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to get subnet %s: %w", subnetSpec.Slug, err)
+		}
+		if status.SubnetIDs == nil {
+			status.SubnetIDs = map[string]string{}
+		}
+		status.SubnetIDs[subnet.Slug] = subnet.ID
+	}
+	coxCluster.Status.Network = status
+	if len(creating) > 0 {
+		conditions.MarkFalse(coxCluster, NetworkReadyCondition, CreatingNetworkReason, clusterv1.ConditionSeverityInfo, "Creating subnets %s", strings.Join(creating, ", "))
+		return false, nil
+	}
+	conditions.MarkTrue(coxCluster, NetworkReadyCondition)
+	return true, nil
+}
+
+// deleteNetwork deletes the subnets and VPC that have been created for the
+// cluster, leaving those that already existed, which may be shared with other
+// clusters. It returns the ones that still exist.
+func (r *CoxClusterReconciler) deleteNetwork(ctx context.Context, clusterScope *scope.ClusterScope) ([]string, error) {
+	log := ctrl.LoggerFrom(ctx)
+	network := clusterScope.CoxCluster.Spec.Network
+	created := clusterScope.CoxCluster.Status.Network
+	if created == nil || (!created.CreatedVPC && len(created.CreatedSubnets) == 0) {
+		return nil, nil
+	}
+	vpc, err := clusterScope.CoxClient.GetVPCBySlug(network.VPC.Slug)
+	if errors.Is(err, coxedge.ErrVPCNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var remaining []string
+	for _, slug := range created.CreatedSubnets {
+		subnet, err := clusterScope.CoxClient.GetSubnetBySlug(vpc.ID, slug)
+		if errors.Is(err, coxedge.ErrSubnetNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Deleting subnet", "slug", subnet.Slug)
+		if _, err := clusterScope.CoxClient.DeleteSubnet(subnet.ID); err != nil {
+			return nil, fmt.Errorf("failed to delete subnet %s: %w", subnet.Slug, err)
+		}
+		remaining = append(remaining, "subnet "+subnet.Slug)
+	}
+	if len(remaining) > 0 || !created.CreatedVPC {
+		return remaining, nil
+	}
+
+	log.Info("Deleting VPC", "slug", vpc.Slug)
+	if _, err := clusterScope.CoxClient.DeleteVPC(vpc.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete VPC %s: %w", vpc.Slug, err)
+	}
+	return []string{"VPC " + vpc.Slug}, nil
+}
+
+func nameOrSlug(name, slug string) string {
+	if len(name) > 0 {
+		return name
+	}
+	return slug
+}
+
+// loadBalancerNetworkInterfaces attaches the load balancers of the cluster to
+// the first subnet of its VPC, keeping their public IP. The interfaces have
+// the IP families of the cluster, so that the load balancers can reach
+// IPv6 backends.
+func loadBalancerNetworkInterfaces(cluster *clusterv1.Cluster, network *coxv1.CoxNetworkSpec) []coxv1.NetworkInterface {
+	ipFamilies := clusterIPFamilies(cluster)
+	if network == nil && ipFamilies == coxedge.IPFamiliesIPv4 {
+		return nil
+	}
+	networkInterface := coxv1.NetworkInterface{IPFamilies: ipFamilies}
+	if network != nil {
+		networkInterface.VPCSlug = network.VPC.Slug
+		if len(network.Subnets) > 0 {
+			networkInterface.SubnetSlug = network.Subnets[0].Slug
+		}
+	}
+	return []coxv1.NetworkInterface{networkInterface}
+}
+
+// clusterIPFamilies returns the IP families of the network interfaces of a
+// cluster, derived from the pod and service CIDRs of its cluster network.
+func clusterIPFamilies(cluster *clusterv1.Cluster) string {
+	if cluster == nil {
+		return coxedge.IPFamiliesIPv4
+	}
+	ipFamily, err := cluster.GetIPFamily()
+	if err != nil {
+		return coxedge.IPFamiliesIPv4
+	}
+	switch ipFamily {
+	case clusterv1.IPv6IPFamily:
+		return coxedge.IPFamiliesIPv6
+	case clusterv1.DualStackIPFamily:
+		return coxedge.IPFamiliesDualStack
+	default:
+		return coxedge.IPFamiliesIPv4
+	}
+}
+
+// networkInterfaces returns the network interfaces of a workload, defaulting
+// to a single interface with a public IP in the VPC of the cluster.
+func networkInterfaces(specified []coxv1.NetworkInterface, network *coxv1.CoxNetworkSpec) []coxedge.NetworkInterface {
+	if len(specified) == 0 {
+		specified = []coxv1.NetworkInterface{{}}
+	}
+	var result []coxedge.NetworkInterface
+	for _, ni := range specified {
+		n := coxedge.NetworkInterface{
+			VPCSlug:    ni.VPCSlug,
+			SubnetSlug: ni.SubnetSlug,
+			IPFamilies: ni.IPFamilies,
+			IsPublicIP: ni.IsPublicIP == nil || *ni.IsPublicIP,
+		}
+		if len(n.VPCSlug) == 0 {
+			n.VPCSlug = coxedge.VPCDefault
+			if network != nil {
+				n.VPCSlug = network.VPC.Slug
+			}
+		}
+		if len(n.SubnetSlug) == 0 && network != nil && n.VPCSlug == network.VPC.Slug && len(network.Subnets) > 0 {
+			n.SubnetSlug = network.Subnets[0].Slug
+		}
+		if len(n.IPFamilies) == 0 {
+			n.IPFamilies = coxedge.IPFamiliesIPv4
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+// machineAddress returns the first address of the given type of the machine.
+func machineAddress(coxMachine *coxv1.CoxMachine, addressType corev1.NodeAddressType) string {
+	for _, addr := range coxMachine.Status.Addresses {
+		if addr.Type == addressType && len(addr.Address) > 0 {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+// workerBackendPorts returns the ports of the workers the workers load
+// balancer routes to.
+func workerBackendPorts(coxCluster *coxv1.CoxCluster) []string {
+	if len(coxCluster.Spec.WorkersLoadBalancer.Ports) == 0 {
+		return []string{strconv.Itoa(defaultWorkerLBPort)}
+	}
+	return coxCluster.Spec.WorkersLoadBalancer.Ports
+}
+
+// reconcileNetworkPolicy applies the network policy of the cluster to the
+// workloads of the control plane load balancer and the machines.
+func (r *CoxClusterReconciler) reconcileNetworkPolicy(ctx context.Context, clusterScope *scope.ClusterScope, coxMachines []coxv1.CoxMachine, controlPlaneLoadBalancer, workersLoadBalancer *coxv1.CoxLoadBalancer) error {
+	log := ctrl.LoggerFrom(ctx)
+	coxCluster := clusterScope.CoxCluster
+	policy := coxCluster.Spec.NetworkPolicy
+	if policy == nil {
+		conditions.Delete(coxCluster, NetworkPolicyReadyCondition)
+		return nil
+	}
+
+	sources := machineTrafficSources{
+		cluster:   clusterSources(coxCluster, coxMachines),
+		apiServer: apiServerAllowedCIDRs(policy),
+	}
+	if workersLoadBalancer != nil {
+		sources.workers = loadBalancerSources(workersLoadBalancer)
+		sources.workerPorts = workerBackendPorts(coxCluster)
+	}
+	if controlPlaneLoadBalancer != nil {
+		// The control plane machines only have to be reachable through the
+		// load balancer. A replacement load balancer is allowed in once it
+		// has become the active one.
+		sources.apiServer = loadBalancerSources(controlPlaneLoadBalancer)
+		if workloadID := controlPlaneLoadBalancer.Status.WorkloadID; len(workloadID) > 0 {
+			rules := loadBalancerNetworkPolicyRules(policy, controlPlaneLoadBalancer.Spec.Ports)
+			changed, err := clusterScope.CoxClient.ReconcileNetworkPolicyRules(workloadID, rules)
+			if err != nil {
+				return fmt.Errorf("failed to reconcile the network policy of load balancer %s: %w", controlPlaneLoadBalancer.Name, err)
+			}
+			if changed {
+				log.Info("Updated the network policy of the control plane load balancer", "workloadID", workloadID)
+			}
+		}
+	}
+
+	for _, coxMachine := range coxMachines {
+		if !coxMachine.DeletionTimestamp.IsZero() || coxMachine.Spec.ProviderID == "" {
+			continue
+		}
+		_, controlPlane := coxMachine.Labels[clusterv1.MachineControlPlaneLabelName]
+		rules := machineNetworkPolicyRules(policy, controlPlane, sources)
+		workloadID := strings.TrimPrefix(coxMachine.Spec.ProviderID, "coxedge://")
+		changed, err := clusterScope.CoxClient.ReconcileNetworkPolicyRules(workloadID, rules)
+		if err != nil {
+			return fmt.Errorf("failed to reconcile the network policy of machine %s: %w", coxMachine.Name, err)
+		}
+		if changed {
+			log.Info("Updated the network policy of the machine", "machine", coxMachine.Name, "workloadID", workloadID)
+		}
+	}
+	conditions.MarkTrue(coxCluster, NetworkPolicyReadyCondition)
+	return nil
+}
+
+// apiServerAllowedCIDRs returns the sources allowed to reach the control
+// plane endpoint.
+func apiServerAllowedCIDRs(policy *coxv1.CoxNetworkPolicy) []string {
+	if len(policy.APIServerAllowedCIDRs) == 0 {
+		return []string{coxedge.AnySource}
+	}
+	return policy.APIServerAllowedCIDRs
+}
+
+// loadBalancerSources returns the addresses the instances of the load
+// balancer connect to its backends from: their public IPs, and their private
+// IPs for backends in the VPC.
+func loadBalancerSources(coxLoadBalancer *coxv1.CoxLoadBalancer) []string {
+	var sources []string
+	for _, instance := range coxLoadBalancer.Status.Instances {
+		for _, address := range append([]string{instance.PublicIP}, instance.PrivateIPs...) {
+			if source := hostSource(address); len(source) > 0 {
+				sources = append(sources, source)
+			}
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// clusterSources returns the sources of the traffic between the machines of
+// the cluster, such as etcd, the kubelet or the CNI: the VPC of the cluster
+// and the addresses of all machines, which reach machines in other POPs over
+// their public IPs.
+func clusterSources(coxCluster *coxv1.CoxCluster, coxMachines []coxv1.CoxMachine) []string {
+	var sources []string
+	if network := coxCluster.Spec.Network; network != nil {
+		if len(network.VPC.CIDR) > 0 {
+			sources = append(sources, network.VPC.CIDR)
+		}
+		for _, subnet := range network.Subnets {
+			if len(subnet.CIDR) > 0 {
+				sources = append(sources, subnet.CIDR)
+			}
+		}
+	}
+	for _, coxMachine := range coxMachines {
+		for _, addr := range coxMachine.Status.Addresses {
+			if addr.Type != corev1.NodeExternalIP && addr.Type != corev1.NodeInternalIP {
+				continue
+			}
+			if source := hostSource(addr.Address); len(source) > 0 {
+				sources = append(sources, source)
+			}
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// hostSource returns the CIDR of the single address, or an empty string if it
+// is not an IP address.
+func hostSource(address string) string {
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return ip.String() + "/32"
+	default:
+		return ip.String() + "/128"
+	}
+}
+
+// machineTrafficSources are the sources the machines of a cluster always
+// accept traffic from, regardless of the ingress rules of the policy.
+type machineTrafficSources struct {
+	// cluster are the sources of the traffic between the machines.
+	cluster []string
+	// apiServer are the sources allowed to reach the API server of the
+	// control plane machines.
+	apiServer []string
+	// workers are the sources allowed to reach the workerPorts of the
+	// workers, the instances of the workers load balancer.
+	workers     []string
+	workerPorts []string
+}
+
+// loadBalancerNetworkPolicyRules returns the rules of the control plane load
+// balancer, which only accepts traffic to its ports from the allowed CIDRs.
+func loadBalancerNetworkPolicyRules(policy *coxv1.CoxNetworkPolicy, ports []string) []coxedge.NetworkPolicyRule {
+	var rules []coxedge.NetworkPolicyRule
+	for _, port := range ports {
+		for _, source := range apiServerAllowedCIDRs(policy) {
+			rules = append(rules, coxedge.NetworkPolicyRule{
+				Description: "Kubernetes API server",
+				Protocol:    coxedge.PortProtocolTCP,
+				PortRange:   port,
+				Source:      source,
+			})
+		}
+	}
+	return rules
+}
+
+// machineNetworkPolicyRules returns the rules of a machine: any traffic
+// within the cluster, the ingress rules of the policy and, for control plane
+// machines, access to the API server and for workers access to the backend
+// ports from the load balancers.
+func machineNetworkPolicyRules(policy *coxv1.CoxNetworkPolicy, controlPlane bool, sources machineTrafficSources) []coxedge.NetworkPolicyRule {
+	var rules []coxedge.NetworkPolicyRule
+	for _, source := range sources.cluster {
+		rules = append(rules, coxedge.NetworkPolicyRule{
+			Description: "Cluster",
+			Protocol:    coxedge.NetworkPolicyProtocolAny,
+			Source:      source,
+		})
+	}
+	if controlPlane {
+		for _, source := range sources.apiServer {
+			rules = append(rules, coxedge.NetworkPolicyRule{
+				Description: "Kubernetes API server",
+				Protocol:    coxedge.PortProtocolTCP,
+				PortRange:   fmt.Sprint(defaultKubeApiserverPort),
+				Source:      source,
+			})
+		}
+	} else {
+		for _, port := range sources.workerPorts {
+			for _, source := range sources.workers {
+				rules = append(rules, coxedge.NetworkPolicyRule{
+					Description: "Workers load balancer",
+					Protocol:    coxedge.PortProtocolTCP,
+					PortRange:   port,
+					Source:      source,
+				})
+			}
+		}
+	}
+	for _, ingress := range policy.Ingress {
+		protocol := ingress.Protocol
+		if len(protocol) == 0 {
+			protocol = coxedge.PortProtocolTCP
+		}
+		sources := ingress.Sources
+		if len(sources) == 0 {
+			sources = []string{coxedge.AnySource}
+		}
+		for _, source := range sources {
+			rules = append(rules, coxedge.NetworkPolicyRule{
+				Description: ingress.Description,
+				Protocol:    protocol,
+				PortRange:   ingress.PortRange,
+				Source:      source,
+			})
+		}
+	}
+	return rules
+}
+
+// failureDomains returns the Cluster API failure domains of the POPs
+// configured on the CoxCluster.
+func failureDomains(coxCluster *coxv1.CoxCluster) clusterv1.FailureDomains {
+	if len(coxCluster.Spec.FailureDomains) == 0 {
+		return nil
+	}
+	domains := clusterv1.FailureDomains{}
+	for _, fd := range coxCluster.Spec.FailureDomains {
+		pop := strings.ToUpper(fd.POP)
+		domains[pop] = clusterv1.FailureDomainSpec{
+			ControlPlane: fd.ControlPlane == nil || *fd.ControlPlane,
+		}
+	}
+	return domains
+}
@@ -0,0 +1,500 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	coxv1 "github.com/coxedge/cluster-api-provider-cox/api/v1beta1"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge/scope"
+)
+
+func TestFailureDomains(t *testing.T) {
+	workersOnly := false
+	coxCluster := &coxv1.CoxCluster{
+		Spec: coxv1.CoxClusterSpec{
+			FailureDomains: []coxv1.CoxFailureDomain{
+				{POP: "lax"},
+				{POP: "ORF", ControlPlane: &workersOnly},
+			},
+		},
+	}
+	expected := clusterv1.FailureDomains{
+		"LAX": clusterv1.FailureDomainSpec{ControlPlane: true},
+		"ORF": clusterv1.FailureDomainSpec{ControlPlane: false},
+	}
+	if domains := failureDomains(coxCluster); !reflect.DeepEqual(domains, expected) {
+		t.Errorf("unexpected failure domains: %+v", domains)
+	}
+
+	if domains := failureDomains(&coxv1.CoxCluster{}); domains != nil {
+		t.Errorf("expected no failure domains, got %+v", domains)
+	}
+}
+
+func TestCoxMachineToCoxCluster(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := coxv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	newCluster := func(namespace, infraName string) *clusterv1.Cluster {
+		return &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: namespace},
+			Spec: clusterv1.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{Kind: "CoxCluster", Name: infraName},
+			},
+		}
+	}
+	r := &CoxClusterReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			newCluster("default", "test-abcde"),
+			newCluster("other", "test-fghij"),
+		).Build(),
+	}
+
+	// Clusters with the same name in other namespaces are not matched.
+	machine := newTestCoxMachine("test-0", map[string]string{clusterv1.ClusterLabelName: "test"}, "10.0.0.1")
+	requests := r.CoxMachineToCoxCluster(context.Background())(machine)
+	expected := []ctrl.Request{{NamespacedName: client.ObjectKey{Namespace: "default", Name: "test-abcde"}}}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("unexpected requests: %v", requests)
+	}
+
+	if requests := r.CoxMachineToCoxCluster(context.Background())(newTestCoxMachine("unlabeled", nil, "10.0.0.2")); len(requests) != 0 {
+		t.Errorf("expected no requests for a machine without a cluster, got %v", requests)
+	}
+}
+
+func TestCoxMachineBackendsChanged(t *testing.T) {
+	oldMachine := newTestCoxMachine("test-0", nil, "10.0.0.1")
+
+	conditionsChanged := oldMachine.DeepCopy()
+	conditionsChanged.Status.Conditions = clusterv1.Conditions{{Type: clusterv1.ReadyCondition, Status: corev1.ConditionTrue}}
+	if coxMachineBackendsChanged().Update(event.UpdateEvent{ObjectOld: oldMachine, ObjectNew: conditionsChanged}) {
+		t.Error("expected a condition change to not change the backends")
+	}
+
+	addressChanged := newTestCoxMachine("test-0", nil, "10.0.0.2")
+	if !coxMachineBackendsChanged().Update(event.UpdateEvent{ObjectOld: oldMachine, ObjectNew: addressChanged}) {
+		t.Error("expected an address change to change the backends")
+	}
+
+	deleting := oldMachine.DeepCopy()
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+	if !coxMachineBackendsChanged().Update(event.UpdateEvent{ObjectOld: oldMachine, ObjectNew: deleting}) {
+		t.Error("expected a deletion to change the backends")
+	}
+}
+
+func TestMachineNetworkPolicyRules(t *testing.T) {
+	policy := &coxv1.CoxNetworkPolicy{
+		Ingress: []coxv1.CoxIngressRule{
+			{PortRange: "22", Sources: []string{"192.0.2.0/24", "198.51.100.0/24"}},
+			{Protocol: "UDP", PortRange: "30000-32767"},
+		},
+		APIServerAllowedCIDRs: []string{"203.0.113.0/24"},
+	}
+
+	expected := []coxedge.NetworkPolicyRule{
+		{Protocol: "TCP", PortRange: "22", Source: "192.0.2.0/24"},
+		{Protocol: "TCP", PortRange: "22", Source: "198.51.100.0/24"},
+		{Protocol: "UDP", PortRange: "30000-32767", Source: coxedge.AnySource},
+	}
+	if rules := machineNetworkPolicyRules(policy, false, machineTrafficSources{apiServer: []string{"192.0.2.10/32"}}); !reflect.DeepEqual(rules, expected) {
+		t.Errorf("unexpected worker rules: %+v", rules)
+	}
+
+	// Control plane machines accept API server traffic from the load balancer.
+	coxLoadBalancer := &coxv1.CoxLoadBalancer{}
+	coxLoadBalancer.Status.Instances = []coxv1.CoxLoadBalancerInstanceStatus{{Name: "lb-0", PublicIP: "192.0.2.10"}, {Name: "lb-1"}}
+	rules := machineNetworkPolicyRules(policy, true, machineTrafficSources{apiServer: loadBalancerSources(coxLoadBalancer)})
+	apiServer := coxedge.NetworkPolicyRule{Description: "Kubernetes API server", Protocol: "TCP", PortRange: "6443", Source: "192.0.2.10/32"}
+	if len(rules) != 4 || rules[0] != apiServer {
+		t.Errorf("unexpected control plane rules: %+v", rules)
+	}
+
+	rules = loadBalancerNetworkPolicyRules(policy, []string{"6443"})
+	apiServer.Source = "203.0.113.0/24"
+	if !reflect.DeepEqual(rules, []coxedge.NetworkPolicyRule{apiServer}) {
+		t.Errorf("unexpected load balancer rules: %+v", rules)
+	}
+}
+
+func TestMachineNetworkPolicyRulesKeepClusterTraffic(t *testing.T) {
+	newMachine := func(name string, controlPlane bool, addresses ...corev1.NodeAddress) coxv1.CoxMachine {
+		coxMachine := coxv1.CoxMachine{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}}}
+		if controlPlane {
+			coxMachine.Labels[clusterv1.MachineControlPlaneLabelName] = ""
+		}
+		coxMachine.Status.Addresses = addresses
+		return coxMachine
+	}
+	coxCluster := &coxv1.CoxCluster{Spec: coxv1.CoxClusterSpec{
+		Network:       &coxv1.CoxNetworkSpec{VPC: coxv1.CoxVPCSpec{Slug: "cluster"}},
+		NetworkPolicy: &coxv1.CoxNetworkPolicy{APIServerAllowedCIDRs: []string{"203.0.113.0/24"}},
+	}}
+	coxMachines := []coxv1.CoxMachine{
+		newMachine("control-plane-0", true, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}),
+		// A machine in another POP is reached over its public IP.
+		newMachine("control-plane-1", true, corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "192.0.2.2"}, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "172.16.0.2"}),
+		newMachine("worker-0", false, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.3"}),
+	}
+	// The load balancers reach the machines in the VPC from their private IPs.
+	controlPlaneLoadBalancer, workersLoadBalancer := &coxv1.CoxLoadBalancer{}, &coxv1.CoxLoadBalancer{}
+	controlPlaneLoadBalancer.Status.Instances = []coxv1.CoxLoadBalancerInstanceStatus{{Name: "lb-0", PublicIP: "198.51.100.1", PrivateIPs: []string{"10.0.1.1"}}}
+	workersLoadBalancer.Status.Instances = []coxv1.CoxLoadBalancerInstanceStatus{{Name: "lb-0", PublicIP: "198.51.100.2", PrivateIPs: []string{"10.0.1.2"}}}
+	sources := machineTrafficSources{
+		cluster:     clusterSources(coxCluster, coxMachines),
+		apiServer:   loadBalancerSources(controlPlaneLoadBalancer),
+		workers:     loadBalancerSources(workersLoadBalancer),
+		workerPorts: workerBackendPorts(coxCluster),
+	}
+
+	allowed := func(rules []coxedge.NetworkPolicyRule, port int, source string) bool {
+		for _, rule := range rules {
+			_, cidr, err := net.ParseCIDR(rule.Source)
+			if err != nil || !cidr.Contains(net.ParseIP(source)) {
+				continue
+			}
+			if rule.Protocol == coxedge.NetworkPolicyProtocolAny || (rule.Protocol == coxedge.PortProtocolTCP && rule.PortRange == strconv.Itoa(port)) {
+				return true
+			}
+		}
+		return false
+	}
+	controlPlaneRules := machineNetworkPolicyRules(coxCluster.Spec.NetworkPolicy, true, sources)
+	for _, port := range []int{2379, 2380, 10250} {
+		for _, source := range []string{"10.0.0.3", "192.0.2.2", "172.16.0.2"} {
+			if !allowed(controlPlaneRules, port, source) {
+				t.Errorf("expected port %d of the control plane to be reachable from machine %s, got %+v", port, source, controlPlaneRules)
+			}
+		}
+	}
+	for _, source := range []string{"198.51.100.1", "10.0.1.1"} {
+		if !allowed(controlPlaneRules, defaultKubeApiserverPort, source) {
+			t.Errorf("expected the API server to be reachable from the load balancer at %s, got %+v", source, controlPlaneRules)
+		}
+	}
+	if allowed(controlPlaneRules, 10250, "203.0.113.1") {
+		t.Errorf("expected the kubelet not to be reachable from outside of the cluster, got %+v", controlPlaneRules)
+	}
+
+	workerRules := machineNetworkPolicyRules(coxCluster.Spec.NetworkPolicy, false, sources)
+	for _, source := range []string{"198.51.100.2", "10.0.1.2"} {
+		if !allowed(workerRules, defaultWorkerLBPort, source) {
+			t.Errorf("expected the workers to be reachable from the workers load balancer at %s, got %+v", source, workerRules)
+		}
+	}
+}
+
+func TestNetworkInterfaces(t *testing.T) {
+	if got := networkInterfaces(nil, nil); !reflect.DeepEqual(got, coxedge.DefaultNetworkInterfaces()) {
+		t.Errorf("expected the default network interfaces, got %+v", got)
+	}
+
+	network := &coxv1.CoxNetworkSpec{
+		VPC:     coxv1.CoxVPCSpec{Slug: "cluster"},
+		Subnets: []coxv1.CoxSubnetSpec{{Slug: "nodes"}, {Slug: "other"}},
+	}
+	private := false
+	got := networkInterfaces([]coxv1.NetworkInterface{
+		{IsPublicIP: &private},
+		{VPCSlug: "cluster", SubnetSlug: "other"},
+		{VPCSlug: "shared"},
+	}, network)
+	expected := []coxedge.NetworkInterface{
+		{VPCSlug: "cluster", SubnetSlug: "nodes", IPFamilies: "IPv4", IsPublicIP: false},
+		{VPCSlug: "cluster", SubnetSlug: "other", IPFamilies: "IPv4", IsPublicIP: true},
+		{VPCSlug: "shared", IPFamilies: "IPv4", IsPublicIP: true},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected network interfaces: %+v", got)
+	}
+
+	// The load balancers keep their public IP in the VPC of the cluster.
+	lbInterfaces := networkInterfaces(loadBalancerNetworkInterfaces(&clusterv1.Cluster{}, network), nil)
+	if !reflect.DeepEqual(lbInterfaces, []coxedge.NetworkInterface{{VPCSlug: "cluster", SubnetSlug: "nodes", IPFamilies: "IPv4", IsPublicIP: true}}) {
+		t.Errorf("unexpected load balancer network interfaces: %+v", lbInterfaces)
+	}
+}
+
+func TestLoadBalancerNetworkInterfacesIPFamilies(t *testing.T) {
+	clusterWithCIDRs := func(cidrs ...string) *clusterv1.Cluster {
+		return &clusterv1.Cluster{Spec: clusterv1.ClusterSpec{ClusterNetwork: &clusterv1.ClusterNetwork{
+			Pods: &clusterv1.NetworkRanges{CIDRBlocks: cidrs},
+		}}}
+	}
+	network := &coxv1.CoxNetworkSpec{VPC: coxv1.CoxVPCSpec{Slug: "cluster"}}
+	tests := []struct {
+		name     string
+		cluster  *clusterv1.Cluster
+		network  *coxv1.CoxNetworkSpec
+		expected []coxedge.NetworkInterface
+	}{
+		{
+			name:    "IPv4 without network",
+			cluster: clusterWithCIDRs("192.168.0.0/16"),
+		},
+		{
+			name:     "IPv6 without network",
+			cluster:  clusterWithCIDRs("fd00::/48"),
+			expected: []coxedge.NetworkInterface{{VPCSlug: "default", IPFamilies: "IPv6", IsPublicIP: true}},
+		},
+		{
+			name:     "dual-stack",
+			cluster:  clusterWithCIDRs("192.168.0.0/16", "fd00::/48"),
+			network:  network,
+			expected: []coxedge.NetworkInterface{{VPCSlug: "cluster", IPFamilies: "IPv4,IPv6", IsPublicIP: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specified := loadBalancerNetworkInterfaces(tt.cluster, tt.network)
+			if tt.expected == nil {
+				if specified != nil {
+					t.Errorf("expected the default network interfaces, got %+v", specified)
+				}
+				return
+			}
+			if got := networkInterfaces(specified, nil); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("unexpected load balancer network interfaces: %+v", got)
+			}
+		})
+	}
+}
+
+func TestReconcileClusterBacksOffWhileWaiting(t *testing.T) {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{corev1.AddToScheme, clusterv1.AddToScheme, coxv1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default", UID: "cluster-uid"}}
+	coxCluster := &coxv1.CoxCluster{ObjectMeta: metav1.ObjectMeta{
+		Name:      "cluster",
+		Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "Cluster",
+			Name:       cluster.Name,
+			UID:        cluster.UID,
+		}},
+	}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, coxCluster).Build()
+	r := &CoxClusterReconciler{
+		Client:             c,
+		Scheme:             scheme,
+		Recorder:           record.NewFakeRecorder(100),
+		DefaultCredentials: &scope.Credentials{CoxAPIKey: "key", CoxService: "svc", CoxEnvironment: "env"},
+		RequeuePolicy:      RequeuePolicy{MinInterval: 5 * time.Second, MaxInterval: time.Hour},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(coxCluster)}
+
+	// The load balancers are not ready yet.
+	result, err := r.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != 5*time.Second {
+		t.Fatalf("expected fast polling right after waiting started, got %s", result.RequeueAfter)
+	}
+
+	// Pretend the cluster has been waiting for a while.
+	if err := c.Get(context.Background(), req.NamespacedName, coxCluster); err != nil {
+		t.Fatal(err)
+	}
+	for i := range coxCluster.Status.Conditions {
+		coxCluster.Status.Conditions[i].LastTransitionTime.Time = coxCluster.Status.Conditions[i].LastTransitionTime.Add(-time.Minute)
+	}
+	if err := c.Status().Update(context.Background(), coxCluster); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = r.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter < time.Minute {
+		t.Errorf("expected the requeue interval to back off while the cluster keeps waiting, got %s", result.RequeueAfter)
+	}
+}
+
+func TestReconcileClusterExternalWorkersLoadBalancer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{corev1.AddToScheme, clusterv1.AddToScheme, coxv1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	api := newFakeCoxAPI()
+	defer api.Close()
+	newReconciler := func(objs ...client.Object) *CoxClusterReconciler {
+		return &CoxClusterReconciler{
+			Client:             fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+			Scheme:             scheme,
+			Recorder:           record.NewFakeRecorder(100),
+			DefaultCredentials: &scope.Credentials{CoxAPIKey: "key", CoxService: "svc", CoxEnvironment: "env", CoxAPIBaseURL: api.URL},
+			RequeuePolicy:      RequeuePolicy{MinInterval: 5 * time.Second, MaxInterval: time.Hour},
+		}
+	}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default", UID: "cluster-uid"}}
+	coxCluster := &coxv1.CoxCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster",
+			Namespace: "default",
+			UID:       "coxcluster-uid",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Cluster",
+				Name:       cluster.Name,
+				UID:        cluster.UID,
+			}},
+		},
+		Spec: coxv1.CoxClusterSpec{
+			ControlPlaneEndpoint:     clusterv1.APIEndpoint{Host: "cluster.example.com", Port: 6443},
+			ControlPlaneLoadBalancer: coxv1.CoxLoadBalancerSpec{Type: coxv1.ExternalLoadBalancerType},
+			WorkersLoadBalancer:      coxv1.CoxLoadBalancerSpec{Type: coxv1.ExternalLoadBalancerType},
+		},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(coxCluster)}
+
+	r := newReconciler(cluster, coxCluster)
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	coxLoadBalancers := &coxv1.CoxLoadBalancerList{}
+	if err := r.List(context.Background(), coxLoadBalancers); err != nil {
+		t.Fatal(err)
+	}
+	if len(coxLoadBalancers.Items) > 0 || api.createdWorkloads() > 0 {
+		t.Errorf("expected no load balancers to be deployed for External load balancers, got %d", len(coxLoadBalancers.Items))
+	}
+	reconciled := &coxv1.CoxCluster{}
+	if err := r.Get(context.Background(), req.NamespacedName, reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if !reconciled.Status.Ready || !conditions.IsTrue(reconciled, WorkersLoadBalancerReadyCondition) || !conditions.IsTrue(reconciled, CoxClusterReadyCondition) {
+		t.Errorf("expected the cluster to be ready without workers, got %+v", reconciled.Status.Conditions)
+	}
+
+	// The workers load balancer deployed before the type was switched to
+	// External is deleted.
+	workersLoadBalancer := &coxv1.CoxLoadBalancer{ObjectMeta: metav1.ObjectMeta{Name: "cluster-workers", Namespace: "default"}}
+	if err := controllerutil.SetControllerReference(reconciled, workersLoadBalancer, scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Create(context.Background(), workersLoadBalancer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(workersLoadBalancer), workersLoadBalancer); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the workers load balancer to be deleted, got %v", err)
+	}
+	if err := r.Get(context.Background(), req.NamespacedName, reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if conditions.IsTrue(reconciled, WorkersLoadBalancerReadyCondition) {
+		t.Error("expected the workers load balancer not to be ready while it is deleted")
+	}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(context.Background(), req.NamespacedName, reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if !conditions.IsTrue(reconciled, WorkersLoadBalancerReadyCondition) {
+		t.Errorf("expected the workers load balancer to be ready once deleted, got %+v", reconciled.Status.Conditions)
+	}
+
+	// Deletion does not wait for a workers load balancer.
+	now := metav1.Now()
+	cluster.DeletionTimestamp = &now
+	r = newReconciler(cluster, reconciled)
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(context.Background(), req.NamespacedName, reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if controllerutil.ContainsFinalizer(reconciled, coxv1.ClusterFinalizer) {
+		t.Errorf("expected the cluster infrastructure to be deleted, got %+v", reconciled.Status.Conditions)
+	}
+}
+
+func TestDeleteNetworkKeepsExistingNetwork(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/services/svc/env/vpcs":
+			_ = json.NewEncoder(w).Encode(coxedge.VPCs{Data: []coxedge.VPC{{ID: "vpc", Slug: "shared", CIDR: "10.0.0.0/16"}}})
+		case r.Method == http.MethodGet && r.URL.Path == "/services/svc/env/subnets":
+			_ = json.NewEncoder(w).Encode(coxedge.Subnets{Data: []coxedge.Subnet{
+				{ID: "subnet-shared", VPCID: "vpc", Slug: "shared-nodes", CIDR: "10.0.0.0/24"},
+				{ID: "subnet-cluster", VPCID: "vpc", Slug: "cluster-nodes", CIDR: "10.0.1.0/24"},
+			}})
+		case r.Method == http.MethodPost && r.URL.Query().Get("operation") == "delete":
+			deleted = append(deleted, r.URL.Path)
+			_ = json.NewEncoder(w).Encode(coxedge.POSTResponse{})
+		default:
+			http.Error(w, "not implemented", http.StatusNotImplemented)
+		}
+	}))
+	defer server.Close()
+	coxClient, err := coxedge.NewClient(server.URL, "svc", "env", "key", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both the VPC and the subnets have a CIDR, but only one subnet has been
+	// created for the cluster.
+	coxCluster := &coxv1.CoxCluster{Spec: coxv1.CoxClusterSpec{Network: &coxv1.CoxNetworkSpec{
+		VPC: coxv1.CoxVPCSpec{Slug: "shared", CIDR: "10.0.0.0/16"},
+		Subnets: []coxv1.CoxSubnetSpec{
+			{Slug: "shared-nodes", CIDR: "10.0.0.0/24"},
+			{Slug: "cluster-nodes", CIDR: "10.0.1.0/24"},
+		},
+	}}}
+	coxCluster.Status.Network = &coxv1.CoxNetworkStatus{VPCID: "vpc", CreatedSubnets: []string{"cluster-nodes"}}
+	r := &CoxClusterReconciler{}
+	remaining, err := r.deleteNetwork(context.Background(), &scope.ClusterScope{CoxCluster: coxCluster, CoxClient: coxClient})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"/services/svc/env/subnets/subnet-cluster"}) || !reflect.DeepEqual(remaining, []string{"subnet cluster-nodes"}) {
+		t.Errorf("expected only the subnet created for the cluster to be deleted, deleted %v, remaining %v", deleted, remaining)
+	}
+
+	// An adopted network is left alone.
+	deleted = nil
+	coxCluster.Status.Network = &coxv1.CoxNetworkStatus{VPCID: "vpc"}
+	if remaining, err := r.deleteNetwork(context.Background(), &scope.ClusterScope{CoxCluster: coxCluster, CoxClient: coxClient}); err != nil || len(remaining) > 0 || len(deleted) > 0 {
+		t.Errorf("expected an existing network not to be deleted, deleted %v, remaining %v: %v", deleted, remaining, err)
+	}
+}
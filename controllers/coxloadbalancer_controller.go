@@ -0,0 +1,669 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	coxv1 "github.com/coxedge/cluster-api-provider-cox/api/v1beta1"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge/scope"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	CoxLoadBalancerControllerName = "CoxLoadBalancer"
+
+	// LoadBalancerReadyCondition reports whether the load balancer is serving traffic
+	LoadBalancerReadyCondition clusterv1.ConditionType = "LoadBalancerReady"
+	// LoadBalancerUpToDateCondition reports whether the load balancer matches its spec
+	LoadBalancerUpToDateCondition clusterv1.ConditionType = "LoadBalancerUpToDate"
+
+	// defaultRetiredLoadBalancerGracePeriod is the time a replaced load balancer keeps
+	// running, to allow connections to drain and clients to pick up the new address.
+	defaultRetiredLoadBalancerGracePeriod = 2 * time.Minute
+)
+
+// CoxLoadBalancerReconciler reconciles a CoxLoadBalancer object
+type CoxLoadBalancerReconciler struct {
+	client.Client
+	DefaultCredentials *scope.Credentials
+	Scheme             *runtime.Scheme
+	Recorder           record.EventRecorder
+	// RequeuePolicy determines when objects waiting on Cox Edge and ready
+	// objects are reconciled again.
+	RequeuePolicy RequeuePolicy
+	// ClientFactory creates the Cox Edge clients, rate limited per set of
+	// credentials.
+	ClientFactory *coxedge.ClientFactory
+	// MaxConcurrentReconciles is the number of CoxLoadBalancers reconciled
+	// concurrently.
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=coxloadbalancers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=coxloadbalancers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;patch
+
+// Reconcile ensures that the Cox Edge workload of a CoxLoadBalancer matches its spec.
+func (r *CoxLoadBalancerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	var coxLoadBalancer coxv1.CoxLoadBalancer
+	if err := r.Get(ctx, req.NamespacedName, &coxLoadBalancer); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Load balancers do not need to belong to a Cluster, but honor the pause
+	// of the Cluster if they do.
+	cluster := &clusterv1.Cluster{}
+	if _, ok := coxLoadBalancer.Labels[clusterv1.ClusterLabelName]; ok {
+		owner, err := util.GetClusterFromMetadata(ctx, r.Client, coxLoadBalancer.ObjectMeta)
+		switch {
+		case apierrors.IsNotFound(err):
+			// The Cluster might already be gone while the load balancer is being deleted.
+		case err != nil:
+			return ctrl.Result{}, err
+		default:
+			cluster = owner
+		}
+	}
+	if annotations.IsPaused(cluster, &coxLoadBalancer.ObjectMeta) {
+		log.Info("CoxLoadBalancer or linked Cluster is marked as paused. Won't reconcile")
+		return reconcile.Result{}, nil
+	}
+
+	// Create the load balancer scope
+	lbScope, err := scope.NewLoadBalancerScope(scope.LoadBalancerScopeParams{
+		Logger:             log,
+		Client:             r.Client,
+		CoxLoadBalancer:    &coxLoadBalancer,
+		DefaultCredentials: r.DefaultCredentials,
+		ClientFactory:      r.ClientFactory,
+	})
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to create scope: %+v", err)
+	}
+
+	defer func() {
+		if err := lbScope.Close(); err != nil && reterr == nil {
+			reterr = err
+		}
+	}()
+
+	// Handle deleted load balancers
+	if !coxLoadBalancer.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, lbScope)
+	}
+	return r.reconcileNormal(ctx, lbScope)
+}
+
+func (r *CoxLoadBalancerReconciler) reconcileNormal(ctx context.Context, lbScope *scope.LoadBalancerScope) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	coxLoadBalancer := lbScope.CoxLoadBalancer
+	controllerutil.AddFinalizer(coxLoadBalancer, coxv1.LoadBalancerFinalizer)
+	if len(coxLoadBalancer.Spec.WorkloadName) == 0 {
+		coxLoadBalancer.Spec.WorkloadName = coxLoadBalancer.Name
+	}
+	if coxLoadBalancer.Spec.Credentials != nil && len(coxLoadBalancer.Spec.Credentials.Name) > 0 {
+		if err := scope.EnsureCredentialsMoveLabel(ctx, r.Client, coxLoadBalancer.Namespace, coxLoadBalancer.Spec.Credentials.Name); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	backends, err := r.loadBalancerBackends(ctx, coxLoadBalancer)
+	if err != nil {
+		conditions.MarkFalse(coxLoadBalancer, LoadBalancerReadyCondition, MachineListFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{}, err
+	}
+	hasBackends := len(backends) > 0
+	if !hasBackends {
+		// Needs to be set to some value
+		backends = []string{defaultBackend}
+	}
+
+	image := coxLoadBalancer.Spec.Image
+	if len(image) == 0 {
+		image = defaultLoadBalancerImage
+	}
+	loadBalancerSpec := coxedge.LoadBalancerSpec{
+		Image:    image,
+		Port:     coxLoadBalancer.Spec.Ports,
+		Backends: backends,
+		POP:      coxLoadBalancer.Spec.POP,
+		Anycast:  coxLoadBalancer.Spec.Anycast,
+		Owner:    loadBalancerOwner(coxLoadBalancer),
+	}
+	if len(coxLoadBalancer.Spec.NetworkInterfaces) > 0 {
+		loadBalancerSpec.NetworkInterfaces = networkInterfaces(coxLoadBalancer.Spec.NetworkInterfaces, nil)
+	}
+	setLoadBalancerDeployment(&loadBalancerSpec, &coxLoadBalancer.Spec)
+
+	existingLoadBalancer, err := r.reconcileLoadBalancer(ctx, lbScope, &loadBalancerSpec)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if existingLoadBalancer == nil {
+		return r.RequeuePolicy.WaitingOn(coxLoadBalancer, LoadBalancerReadyCondition), nil
+	}
+	setLoadBalancerStatus(&coxLoadBalancer.Status.CoxLoadBalancerStatus, existingLoadBalancer, &loadBalancerSpec)
+	markLoadBalancerReadyCondition(coxLoadBalancer, existingLoadBalancer, hasBackends)
+	coxLoadBalancer.Status.Ready = len(existingLoadBalancer.Status.PublicIP) > 0
+
+	if err := r.deleteRetiredLoadBalancer(ctx, lbScope); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !coxLoadBalancer.Status.Ready {
+		log.Info("LoadBalancer is not ready yet.")
+		return r.RequeuePolicy.WaitingOn(coxLoadBalancer, LoadBalancerReadyCondition), nil
+	}
+	if coxLoadBalancer.Status.Rollout != nil {
+		// Keep polling while the load balancer is being replaced.
+		return r.RequeuePolicy.WaitingOn(coxLoadBalancer, LoadBalancerUpToDateCondition), nil
+	}
+	// Requeue to make sure that the controller reconciles drift on the Cox Edge side.
+	return r.RequeuePolicy.Synced(), nil
+}
+
+// loadBalancerBackends returns the static backends of the load balancer
+// together with the addresses of the CoxMachines matching its selector.
+func (r *CoxLoadBalancerReconciler) loadBalancerBackends(ctx context.Context, coxLoadBalancer *coxv1.CoxLoadBalancer) ([]string, error) {
+	backends := append([]string(nil), coxLoadBalancer.Spec.Backends...)
+	if coxLoadBalancer.Spec.BackendSelector == nil {
+		return backends, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(coxLoadBalancer.Spec.BackendSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backend selector: %w", err)
+	}
+	coxMachines := &coxv1.CoxMachineList{}
+	err = r.List(ctx, coxMachines, client.InNamespace(coxLoadBalancer.Namespace), client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	ports := coxLoadBalancer.Spec.BackendPorts
+	if len(ports) == 0 {
+		ports = coxLoadBalancer.Spec.Ports
+	}
+	addressType := coxLoadBalancer.Spec.BackendAddressType
+	if len(addressType) == 0 {
+		addressType = corev1.NodeInternalIP
+	}
+	for _, coxMachine := range coxMachines.Items {
+		if !isLoadBalancerBackend(&coxMachine) {
+			continue
+		}
+		for _, addr := range coxMachine.Status.Addresses {
+			if addr.Type != addressType {
+				continue
+			}
+			for _, port := range ports {
+				backends = append(backends, net.JoinHostPort(addr.Address, port))
+			}
+			break
+		}
+	}
+	sort.Strings(backends)
+	return backends, nil
+}
+
+// loadBalancerOwner returns the ownership marker of the workloads of a load
+// balancer that is managed by a CoxCluster.
+func loadBalancerOwner(coxLoadBalancer *coxv1.CoxLoadBalancer) string {
+	owner := metav1.GetControllerOf(coxLoadBalancer)
+	if owner == nil || owner.Kind != "CoxCluster" {
+		return ""
+	}
+	return clusterLoadBalancerOwner(coxLoadBalancer.Namespace, owner.Name)
+}
+
+// clusterLoadBalancerOwner returns the ownership marker of the load balancer
+// workloads of a CoxCluster: its namespace and name, which unlike its UID are
+// kept by clusterctl move.
+func clusterLoadBalancerOwner(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// replacementLoadBalancerName returns the name of the workload replacing the
+// load balancer with one that has the given spec.
+func replacementLoadBalancerName(coxLoadBalancer *coxv1.CoxLoadBalancer, loadBalancerSpec *coxedge.LoadBalancerSpec) string {
+	return fmt.Sprintf("%s-%s", coxLoadBalancer.Spec.WorkloadName, loadBalancerSpec.Hash())
+}
+
+// activeLoadBalancerName returns the name of the workload that is currently serving traffic.
+func activeLoadBalancerName(coxLoadBalancer *coxv1.CoxLoadBalancer) string {
+	if len(coxLoadBalancer.Status.Name) > 0 {
+		return coxLoadBalancer.Status.Name
+	}
+	return coxLoadBalancer.Spec.WorkloadName
+}
+
+// reconcileLoadBalancer ensures that the load balancer described by the spec
+// exists and routes to the desired backends. It returns nil if the load
+// balancer has just been created and is not available yet. Failures are
+// reported through the conditions of the CoxLoadBalancer.
+//
+// Changes to the image, POPs or instance spec are rolled out by creating a
+// replacement next to the current load balancer. Once the replacement is
+// running, it is returned instead of the current one and the current one is
+// marked as retired.
+func (r *CoxLoadBalancerReconciler) reconcileLoadBalancer(ctx context.Context, lbScope *scope.LoadBalancerScope, loadBalancerSpec *coxedge.LoadBalancerSpec) (*coxedge.LoadBalancer, error) {
+	log := ctrl.LoggerFrom(ctx)
+	coxLoadBalancer := lbScope.CoxLoadBalancer
+	status := &coxLoadBalancer.Status
+	lbClient := coxedge.NewLoadBalancerHelper(lbScope.CoxClient)
+	loadBalancerSpec.Name = activeLoadBalancerName(coxLoadBalancer)
+
+	existingLoadBalancer, err := lbClient.GetLoadBalancer(ctx, loadBalancerSpec.Name)
+	if err == coxedge.ErrWorkloadNotFound && len(status.Name) == 0 {
+		// The status is not preserved when the object is moved to another
+		// management cluster. The replacement of a completed rollout has a
+		// name derived from the spec, so it is adopted instead of creating a
+		// new load balancer next to it.
+		existingLoadBalancer, err = lbClient.GetLoadBalancer(ctx, replacementLoadBalancerName(coxLoadBalancer, loadBalancerSpec))
+		if err == nil {
+			log.Info("Adopting the replacement LoadBalancer", "name", existingLoadBalancer.Spec.Name)
+			status.Name = existingLoadBalancer.Spec.Name
+		}
+	}
+	if err != nil {
+		if err != coxedge.ErrWorkloadNotFound {
+			conditions.MarkFalse(coxLoadBalancer, LoadBalancerReadyCondition, LoadBalancerNotFoundReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return nil, err
+		}
+		err = lbClient.CreateLoadBalancer(ctx, loadBalancerSpec)
+		if err != nil {
+			r.Recorder.Eventf(coxLoadBalancer, corev1.EventTypeWarning, "CreatingLoadBalancerFailed", "Failed to create loadbalancer %s: %v", loadBalancerSpec.Name, err)
+			conditions.MarkFalse(coxLoadBalancer, LoadBalancerReadyCondition, LoadBalancerCreateFailedReason, clusterv1.ConditionSeverityError, err.Error())
+			return nil, err
+		}
+		log.Info("Created LoadBalancer deployment", "spec", loadBalancerSpec)
+		r.Recorder.Eventf(coxLoadBalancer, corev1.EventTypeNormal, "CreatedLoadBalancer", "Created LoadBalancer %s", loadBalancerSpec.Name)
+		conditions.MarkFalse(coxLoadBalancer, LoadBalancerReadyCondition, WaitingLoadBalancerReason, clusterv1.ConditionSeverityInfo, "Creating LoadBalancer deployment")
+		return nil, nil
+	}
+
+	// Ignore the name of the existing one because it might have been shortened.
+	loadBalancerSpec.Name = existingLoadBalancer.Spec.Name
+	requiresReplacement := existingLoadBalancer.Spec.RequiresReplacement(loadBalancerSpec)
+
+	// Changes that require a replacement must not be applied in place.
+	inPlaceSpec := *loadBalancerSpec
+	if requiresReplacement {
+		inPlaceSpec.POP = existingLoadBalancer.Spec.POP
+		inPlaceSpec.Specs = existingLoadBalancer.Spec.Specs
+	}
+	err = updateLoadBalancer(ctx, lbClient, existingLoadBalancer, &inPlaceSpec)
+	if err != nil {
+		conditions.MarkFalse(coxLoadBalancer, LoadBalancerReadyCondition, LoadBalancerUpdateFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return nil, err
+	}
+
+	if !requiresReplacement {
+		if status.Rollout != nil && len(status.Rollout.Name) > 0 {
+			// The spec was reverted while a replacement was being created.
+			if err := lbClient.DeleteLoadBalancer(ctx, status.Rollout.Name); err != nil {
+				return nil, err
+			}
+			status.Rollout.Name = ""
+		}
+		if status.Rollout == nil || len(status.Rollout.RetiredName) == 0 {
+			status.Rollout = nil
+			conditions.MarkTrue(coxLoadBalancer, LoadBalancerUpToDateCondition)
+		}
+		return existingLoadBalancer, nil
+	}
+
+	if coxLoadBalancer.Spec.ReplacementStrategy == coxv1.NoReplacementStrategy {
+		conditions.MarkFalse(coxLoadBalancer, LoadBalancerUpToDateCondition, LoadBalancerRolloutBlockedReason, clusterv1.ConditionSeverityWarning,
+			"Changing the image, POPs or specs requires replacing the load balancer, which is disabled by the replacement strategy")
+		return existingLoadBalancer, nil
+	}
+	if status.Rollout != nil && len(status.Rollout.RetiredName) > 0 {
+		// Finish retiring the previous load balancer before starting another rollout.
+		return existingLoadBalancer, nil
+	}
+
+	replacement, err := r.reconcileReplacementLoadBalancer(ctx, lbScope, loadBalancerSpec)
+	if err != nil {
+		conditions.MarkFalse(coxLoadBalancer, LoadBalancerUpToDateCondition, LoadBalancerRolloutFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return nil, err
+	}
+	if replacement == nil {
+		return existingLoadBalancer, nil
+	}
+
+	log.Info("Cutting over to the replacement LoadBalancer", "old", existingLoadBalancer.Spec.Name, "new", replacement.Spec.Name)
+	r.Recorder.Eventf(coxLoadBalancer, corev1.EventTypeNormal, "CutOverLoadBalancer", "Replaced LoadBalancer %s with %s", existingLoadBalancer.Spec.Name, replacement.Spec.Name)
+	now := metav1.Now()
+	status.Name = replacement.Spec.Name
+	status.Rollout = &coxv1.CoxLoadBalancerRolloutStatus{
+		RetiredName: existingLoadBalancer.Spec.Name,
+		RetiredTime: &now,
+	}
+	conditions.MarkFalse(coxLoadBalancer, LoadBalancerUpToDateCondition, RetiringLoadBalancerReason, clusterv1.ConditionSeverityInfo, "Waiting to delete the replaced LoadBalancer %s", existingLoadBalancer.Spec.Name)
+	return replacement, nil
+}
+
+// reconcileReplacementLoadBalancer creates the replacement of a load balancer
+// and returns it once it is running with the desired backends.
+func (r *CoxLoadBalancerReconciler) reconcileReplacementLoadBalancer(ctx context.Context, lbScope *scope.LoadBalancerScope, loadBalancerSpec *coxedge.LoadBalancerSpec) (*coxedge.LoadBalancer, error) {
+	log := ctrl.LoggerFrom(ctx)
+	coxLoadBalancer := lbScope.CoxLoadBalancer
+	status := &coxLoadBalancer.Status
+	lbClient := coxedge.NewLoadBalancerHelper(lbScope.CoxClient)
+	replacementSpec := *loadBalancerSpec
+	replacementSpec.Name = replacementLoadBalancerName(coxLoadBalancer, loadBalancerSpec)
+
+	if status.Rollout != nil && len(status.Rollout.Name) > 0 && status.Rollout.Name != replacementSpec.Name {
+		// The spec changed again while the previous replacement was being created.
+		log.Info("Deleting outdated replacement LoadBalancer", "name", status.Rollout.Name)
+		if err := lbClient.DeleteLoadBalancer(ctx, status.Rollout.Name); err != nil {
+			return nil, err
+		}
+	}
+	status.Rollout = &coxv1.CoxLoadBalancerRolloutStatus{
+		Name: replacementSpec.Name,
+	}
+
+	replacement, err := lbClient.GetLoadBalancer(ctx, replacementSpec.Name)
+	if err != nil {
+		if err != coxedge.ErrWorkloadNotFound {
+			return nil, err
+		}
+		if err := lbClient.CreateLoadBalancer(ctx, &replacementSpec); err != nil {
+			r.Recorder.Eventf(coxLoadBalancer, corev1.EventTypeWarning, "CreatingLoadBalancerFailed", "Failed to create replacement loadbalancer %s: %v", replacementSpec.Name, err)
+			return nil, err
+		}
+		log.Info("Created replacement LoadBalancer deployment", "spec", replacementSpec)
+		r.Recorder.Eventf(coxLoadBalancer, corev1.EventTypeNormal, "CreatedLoadBalancer", "Created replacement LoadBalancer %s", replacementSpec.Name)
+		conditions.MarkFalse(coxLoadBalancer, LoadBalancerUpToDateCondition, LoadBalancerRolloutInProgressReason, clusterv1.ConditionSeverityInfo, "Creating replacement LoadBalancer %s", replacementSpec.Name)
+		return nil, nil
+	}
+
+	replacementSpec.Name = replacement.Spec.Name
+	status.Rollout.Name = replacement.Spec.Name
+	if err := updateLoadBalancer(ctx, lbClient, replacement, &replacementSpec); err != nil {
+		return nil, err
+	}
+	desiredInstances := replacementSpec.DesiredInstances()
+	if len(replacement.Status.PublicIP) == 0 || replacement.Status.ReadyInstances < desiredInstances {
+		conditions.MarkFalse(coxLoadBalancer, LoadBalancerUpToDateCondition, LoadBalancerRolloutInProgressReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for replacement LoadBalancer %s to be running (%d/%d)", replacement.Spec.Name, replacement.Status.ReadyInstances, desiredInstances)
+		return nil, nil
+	}
+	return replacement, nil
+}
+
+// updateLoadBalancer updates the backends and deployment of an existing load
+// balancer in place, if they differ from the desired spec. The status of the
+// load balancer is reset if it has been updated.
+func updateLoadBalancer(ctx context.Context, lbClient *coxedge.LoadBalancerHelper, existingLoadBalancer *coxedge.LoadBalancer, loadBalancerSpec *coxedge.LoadBalancerSpec) error {
+	log := ctrl.LoggerFrom(ctx)
+	//Sort Backends Addresses before running DeepEqual, else objects will return false resulting in LB getting restarted every few seconds in MultiMaster Mode
+	sort.Strings(loadBalancerSpec.Backends)
+	sort.Strings(existingLoadBalancer.Spec.Backends)
+	if reflect.DeepEqual(existingLoadBalancer.Spec.Backends, loadBalancerSpec.Backends) &&
+		existingLoadBalancer.Spec.Anycast == loadBalancerSpec.Anycast &&
+		existingLoadBalancer.Spec.DeploymentEqual(loadBalancerSpec) {
+		return nil
+	}
+
+	err := lbClient.UpdateLoadBalancer(ctx, loadBalancerSpec)
+	if err != nil {
+		return err
+	}
+	log.Info("Updated LoadBalancer deployment", "old", existingLoadBalancer.Spec, "new", loadBalancerSpec)
+	existingLoadBalancer.Spec.Backends = loadBalancerSpec.Backends
+	existingLoadBalancer.Status = coxedge.LoadBalancerStatus{
+		WorkloadID: existingLoadBalancer.Status.WorkloadID,
+	}
+	return nil
+}
+
+// deleteRetiredLoadBalancer deletes a load balancer that has been replaced,
+// once clients had the time to move to its replacement.
+func (r *CoxLoadBalancerReconciler) deleteRetiredLoadBalancer(ctx context.Context, lbScope *scope.LoadBalancerScope) error {
+	coxLoadBalancer := lbScope.CoxLoadBalancer
+	rollout := coxLoadBalancer.Status.Rollout
+	if rollout == nil || len(rollout.RetiredName) == 0 {
+		return nil
+	}
+
+	gracePeriod := defaultRetiredLoadBalancerGracePeriod
+	if coxLoadBalancer.Spec.RetirementGracePeriod != nil {
+		gracePeriod = coxLoadBalancer.Spec.RetirementGracePeriod.Duration
+	}
+	if rollout.RetiredTime != nil && time.Since(rollout.RetiredTime.Time) < gracePeriod {
+		return nil
+	}
+
+	lbClient := coxedge.NewLoadBalancerHelper(lbScope.CoxClient)
+	if err := lbClient.DeleteLoadBalancer(ctx, rollout.RetiredName); err != nil {
+		r.Recorder.Eventf(coxLoadBalancer, corev1.EventTypeWarning, "DeletingLoadBalancerFailed", "Failed to delete replaced loadbalancer %s: %v", rollout.RetiredName, err)
+		return err
+	}
+	r.Recorder.Eventf(coxLoadBalancer, corev1.EventTypeNormal, "DeletedLoadBalancer", "Deleted replaced LoadBalancer %s", rollout.RetiredName)
+	coxLoadBalancer.Status.Rollout = nil
+	conditions.MarkTrue(coxLoadBalancer, LoadBalancerUpToDateCondition)
+	return nil
+}
+
+func (r *CoxLoadBalancerReconciler) reconcileDelete(ctx context.Context, lbScope *scope.LoadBalancerScope) (ctrl.Result, error) {
+	coxLoadBalancer := lbScope.CoxLoadBalancer
+	lbClient := coxedge.NewLoadBalancerHelper(lbScope.CoxClient)
+	for _, name := range loadBalancerNames(coxLoadBalancer.Spec.WorkloadName, &coxLoadBalancer.Status.CoxLoadBalancerStatus) {
+		err := lbClient.DeleteLoadBalancer(ctx, name)
+		if err != nil {
+			r.Recorder.Eventf(coxLoadBalancer, corev1.EventTypeWarning, "DeletingLoadBalancerFailed", "Failed to delete loadbalancer %s: %v", name, err)
+			return ctrl.Result{}, err
+		}
+	}
+	r.Recorder.Eventf(coxLoadBalancer, corev1.EventTypeNormal, "DeletedLoadBalancer", "Deleted LoadBalancer %s", activeLoadBalancerName(coxLoadBalancer))
+	controllerutil.RemoveFinalizer(coxLoadBalancer, coxv1.LoadBalancerFinalizer)
+	return ctrl.Result{}, nil
+}
+
+// loadBalancerNames returns the names of all workloads that may belong to a
+// load balancer, including the ones involved in a rollout.
+func loadBalancerNames(defaultName string, status *coxv1.CoxLoadBalancerStatus) []string {
+	var names []string
+	if len(defaultName) > 0 {
+		names = append(names, defaultName)
+	}
+	if len(status.Name) > 0 && status.Name != defaultName {
+		names = append(names, status.Name)
+	}
+	if status.Rollout != nil {
+		for _, name := range []string{status.Rollout.Name, status.Rollout.RetiredName} {
+			if len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CoxLoadBalancerReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(&coxv1.CoxLoadBalancer{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		WithEventFilter(predicates.ResourceNotPaused(ctrl.LoggerFrom(ctx))). // don't queue reconcile if resource is paused
+		Watches(
+			&source.Kind{Type: &coxv1.CoxMachine{}},
+			handler.EnqueueRequestsFromMapFunc(r.CoxMachineToCoxLoadBalancers(ctx)),
+		).
+		Build(r)
+	if err != nil {
+		return fmt.Errorf("error creating controller: %w", err)
+	}
+
+	clusterToObjectFunc, err := util.ClusterToObjectsMapper(r.Client, &coxv1.CoxLoadBalancerList{}, mgr.GetScheme())
+	if err != nil {
+		return fmt.Errorf("failed to create mapper for Cluster to CoxLoadBalancers: %w", err)
+	}
+
+	// Add a watch on clusterv1.Cluster object for unpause notifications.
+	if err := c.Watch(
+		&source.Kind{Type: &clusterv1.Cluster{}},
+		handler.EnqueueRequestsFromMapFunc(clusterToObjectFunc),
+		predicates.ClusterUnpaused(ctrl.LoggerFrom(ctx)),
+	); err != nil {
+		return fmt.Errorf("failed adding a watch for ready clusters: %w", err)
+	}
+
+	return nil
+}
+
+// CoxMachineToCoxLoadBalancers maps a CoxMachine to the CoxLoadBalancers
+// whose backend selector matches it, so that address changes are picked up
+// without waiting for the next periodic reconcile.
+func (r *CoxLoadBalancerReconciler) CoxMachineToCoxLoadBalancers(ctx context.Context) handler.MapFunc {
+	log := ctrl.LoggerFrom(ctx)
+	return func(o client.Object) []ctrl.Request {
+		var result []ctrl.Request
+
+		m, ok := o.(*coxv1.CoxMachine)
+		if !ok {
+			log.Error(fmt.Errorf("expected a CoxMachine but got a %T", o), "failed to get CoxLoadBalancers for CoxMachine")
+			return nil
+		}
+
+		lbList := &coxv1.CoxLoadBalancerList{}
+		if err := r.List(ctx, lbList, client.InNamespace(m.Namespace)); err != nil {
+			log.Error(err, "failed to list CoxLoadBalancers")
+			return nil
+		}
+		for _, lb := range lbList.Items {
+			if lb.Spec.BackendSelector == nil {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(lb.Spec.BackendSelector)
+			if err != nil || !selector.Matches(labels.Set(m.Labels)) {
+				continue
+			}
+			result = append(result, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&lb)})
+		}
+
+		return result
+	}
+}
+
+// isLoadBalancerBackend returns whether load balancers may route to the
+// machine. Deleting machines are drained before their workload is deleted.
+func isLoadBalancerBackend(coxMachine *coxv1.CoxMachine) bool {
+	return coxMachine.DeletionTimestamp.IsZero()
+}
+
+// setLoadBalancerDeployment sets the number of instances and their flavor
+// from the CoxLoadBalancer spec, applying defaults where necessary. Without a
+// size or autoscaling, load balancers autoscale like they always did.
+func setLoadBalancerDeployment(spec *coxedge.LoadBalancerSpec, lbSpec *coxv1.CoxLoadBalancerResourceSpec) {
+	spec.Specs = lbSpec.Specs
+	if len(spec.Specs) == 0 {
+		spec.Specs = coxedge.SpecSP1
+	}
+	switch {
+	case lbSpec.AutoScaling != nil:
+		spec.AutoScaling = &coxedge.LoadBalancerAutoScaling{
+			MinInstancesPerPop: lbSpec.AutoScaling.MinInstancesPerPop,
+			MaxInstancesPerPop: lbSpec.AutoScaling.MaxInstancesPerPop,
+			CPUUtilization:     lbSpec.AutoScaling.CPUUtilization,
+		}
+		if spec.AutoScaling.CPUUtilization == 0 {
+			spec.AutoScaling.CPUUtilization = defaultLoadBalancerCPUUtilization
+		}
+	case len(lbSpec.Size) > 0:
+		spec.Instances = lbSpec.Size
+	default:
+		spec.AutoScaling = &coxedge.LoadBalancerAutoScaling{
+			MinInstancesPerPop: defaultLoadBalancerMinInstancesPerPop,
+			MaxInstancesPerPop: defaultLoadBalancerMaxInstancesPerPop,
+			CPUUtilization:     defaultLoadBalancerCPUUtilization,
+		}
+	}
+}
+
+// setLoadBalancerStatus copies the observed state of the load balancer to its status.
+func setLoadBalancerStatus(status *coxv1.CoxLoadBalancerStatus, lb *coxedge.LoadBalancer, desired *coxedge.LoadBalancerSpec) {
+	if len(lb.Status.PublicIP) > 0 {
+		status.PublicIP = lb.Status.PublicIP
+	}
+	status.WorkloadID = lb.Status.WorkloadID
+	status.Name = lb.Spec.Name
+	status.Image = lb.Spec.Image
+	status.Ports = lb.Spec.Port
+	status.Backends = lb.Spec.Backends
+	status.DesiredInstances = int32(desired.DesiredInstances())
+	status.ReadyInstances = int32(lb.Status.ReadyInstances)
+	status.AnycastIP = lb.Status.AnycastIP
+	status.Addresses = lb.Status.Addresses()
+	status.Instances = nil
+	for _, inst := range lb.Status.Instances {
+		status.Instances = append(status.Instances, coxv1.CoxLoadBalancerInstanceStatus{
+			Name:       inst.Name,
+			POP:        inst.POP,
+			PublicIP:   inst.PublicIP,
+			PrivateIPs: inst.PrivateIPs,
+			State:      inst.Status,
+		})
+	}
+}
+
+// markLoadBalancerReadyCondition sets the readiness condition of a load balancer.
+func markLoadBalancerReadyCondition(coxLoadBalancer *coxv1.CoxLoadBalancer, lb *coxedge.LoadBalancer, hasBackends bool) {
+	switch {
+	case len(lb.Status.PublicIP) == 0 && lb.Spec.Anycast:
+		conditions.MarkFalse(coxLoadBalancer, LoadBalancerReadyCondition, LoadBalancerNotReadyReason, clusterv1.ConditionSeverityInfo, "LoadBalancer does not have an anycast IP yet")
+	case len(lb.Status.PublicIP) == 0:
+		conditions.MarkFalse(coxLoadBalancer, LoadBalancerReadyCondition, LoadBalancerNotReadyReason, clusterv1.ConditionSeverityInfo, "LoadBalancer does not have a running instance yet")
+	case !hasBackends:
+		conditions.MarkFalse(coxLoadBalancer, LoadBalancerReadyCondition, LoadBalancerInvalidBackendReason, clusterv1.ConditionSeverityInfo, "LoadBalancer does not have any backends yet")
+	default:
+		conditions.MarkTrue(coxLoadBalancer, LoadBalancerReadyCondition)
+	}
+}
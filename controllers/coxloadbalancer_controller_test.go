@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	coxv1 "github.com/coxedge/cluster-api-provider-cox/api/v1beta1"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+)
+
+func newTestCoxMachine(name string, labels map[string]string, internalIP string) *coxv1.CoxMachine {
+	return &coxv1.CoxMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Status: coxv1.CoxMachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeExternalIP, Address: "203.0.113.1"},
+				{Type: corev1.NodeInternalIP, Address: internalIP},
+			},
+		},
+	}
+}
+
+func TestLoadBalancerBackends(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := coxv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	ingress := map[string]string{"ingress": "true"}
+	r := &CoxLoadBalancerReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			newTestCoxMachine("ingress-1", ingress, "10.0.0.2"),
+			newTestCoxMachine("ingress-0", ingress, "10.0.0.1"),
+			newTestCoxMachine("other", map[string]string{"ingress": "false"}, "10.0.0.3"),
+		).Build(),
+	}
+
+	coxLoadBalancer := &coxv1.CoxLoadBalancer{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "default"},
+		Spec: coxv1.CoxLoadBalancerResourceSpec{
+			Ports:           []string{"443"},
+			Backends:        []string{"192.0.2.1:443"},
+			BackendSelector: &metav1.LabelSelector{MatchLabels: ingress},
+		},
+	}
+	backends, err := r.loadBalancerBackends(context.Background(), coxLoadBalancer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"10.0.0.1:443", "10.0.0.2:443", "192.0.2.1:443"}
+	if !reflect.DeepEqual(backends, expected) {
+		t.Errorf("expected backends %v, got %v", expected, backends)
+	}
+
+	coxLoadBalancer.Spec.Backends = nil
+	coxLoadBalancer.Spec.BackendPorts = []string{"30080", "30443"}
+	coxLoadBalancer.Spec.BackendAddressType = corev1.NodeExternalIP
+	backends, err = r.loadBalancerBackends(context.Background(), coxLoadBalancer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"203.0.113.1:30080", "203.0.113.1:30080", "203.0.113.1:30443", "203.0.113.1:30443"}
+	if !reflect.DeepEqual(backends, expected) {
+		t.Errorf("expected backends %v, got %v", expected, backends)
+	}
+
+	requests := r.CoxMachineToCoxLoadBalancers(context.Background())(newTestCoxMachine("ingress-2", ingress, "10.0.0.4"))
+	if len(requests) != 0 {
+		t.Errorf("expected no requests without CoxLoadBalancers, got %v", requests)
+	}
+	if err := r.Create(context.Background(), coxLoadBalancer); err != nil {
+		t.Fatal(err)
+	}
+	requests = r.CoxMachineToCoxLoadBalancers(context.Background())(newTestCoxMachine("ingress-2", ingress, "10.0.0.4"))
+	if len(requests) != 1 || requests[0].Name != "ingress" {
+		t.Errorf("expected a request for the matching CoxLoadBalancer, got %v", requests)
+	}
+	requests = r.CoxMachineToCoxLoadBalancers(context.Background())(newTestCoxMachine("other-2", nil, "10.0.0.5"))
+	if len(requests) != 0 {
+		t.Errorf("expected no requests for a CoxMachine that is not selected, got %v", requests)
+	}
+}
+
+func TestLoadBalancerBackendsIPv6(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := coxv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	ingress := map[string]string{"ingress": "true"}
+	r := &CoxLoadBalancerReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			newTestCoxMachine("ingress-0", ingress, "2001:db8::1"),
+		).Build(),
+	}
+	backends, err := r.loadBalancerBackends(context.Background(), &coxv1.CoxLoadBalancer{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "default"},
+		Spec: coxv1.CoxLoadBalancerResourceSpec{
+			Ports:           []string{"443"},
+			BackendSelector: &metav1.LabelSelector{MatchLabels: ingress},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(backends, []string{"[2001:db8::1]:443"}) {
+		t.Errorf("unexpected backends: %v", backends)
+	}
+}
+
+func TestUpdateLoadBalancerIgnoresOwnerChange(t *testing.T) {
+	api := newFakeCoxAPI()
+	defer api.Close()
+	coxClient, err := coxedge.NewClient(api.URL, "svc", "env", "key", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lbClient := coxedge.NewLoadBalancerHelper(coxClient)
+
+	// Changing the environment variables of a workload restarts its
+	// instances, so load balancers created without an owner, or with the
+	// owner of another cluster, are not marked in place.
+	existing := &coxedge.LoadBalancer{Spec: coxedge.LoadBalancerSpec{
+		Name: "lb", Port: []string{"6443"}, Backends: []string{"10.0.0.1:6443"}, POP: []string{"LAX"},
+	}}
+	if err := lbClient.CreateLoadBalancer(context.Background(), &existing.Spec); err != nil {
+		t.Fatal(err)
+	}
+	desired := existing.Spec
+	desired.Owner = clusterLoadBalancerOwner("default", "cluster")
+	if err := updateLoadBalancer(context.Background(), lbClient, existing, &desired); err != nil {
+		t.Fatal(err)
+	}
+	if updated := api.updatedWorkloads(); updated != 0 {
+		t.Errorf("expected a missing owner not to update the load balancer, got %d updates", updated)
+	}
+
+	existing.Spec.Owner = clusterLoadBalancerOwner("default", "other")
+	if err := updateLoadBalancer(context.Background(), lbClient, existing, &desired); err != nil {
+		t.Fatal(err)
+	}
+	if updated := api.updatedWorkloads(); updated != 0 {
+		t.Errorf("expected a different owner not to update the load balancer, got %d updates", updated)
+	}
+
+	// Updates for other reasons keep the owner of the workload.
+	existing.Spec.Owner = ""
+	desired.Backends = []string{"10.0.0.2:6443"}
+	if err := updateLoadBalancer(context.Background(), lbClient, existing, &desired); err != nil {
+		t.Fatal(err)
+	}
+	if updated := api.updatedWorkloads(); updated != 1 {
+		t.Fatalf("expected the backends to update the load balancer, got %d updates", updated)
+	}
+	updated, err := lbClient.GetLoadBalancer(context.Background(), "lb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Spec.Owner != "" {
+		t.Errorf("expected the update to keep the owner of the load balancer, got %q", updated.Spec.Owner)
+	}
+}
+
+func TestSetLoadBalancerDeployment(t *testing.T) {
+	tests := []struct {
+		name     string
+		lbSpec   coxv1.CoxLoadBalancerResourceSpec
+		expected coxedge.LoadBalancerSpec
+	}{
+		{
+			name:   "default",
+			lbSpec: coxv1.CoxLoadBalancerResourceSpec{},
+			expected: coxedge.LoadBalancerSpec{Specs: coxedge.SpecSP1, AutoScaling: &coxedge.LoadBalancerAutoScaling{
+				MinInstancesPerPop: "1", MaxInstancesPerPop: "3", CPUUtilization: 50,
+			}},
+		},
+		{
+			name:     "size",
+			lbSpec:   coxv1.CoxLoadBalancerResourceSpec{Size: "2", Specs: "SP-2"},
+			expected: coxedge.LoadBalancerSpec{Specs: "SP-2", Instances: "2"},
+		},
+		{
+			name: "autoscaling",
+			lbSpec: coxv1.CoxLoadBalancerResourceSpec{Size: "2", AutoScaling: &coxv1.CoxLoadBalancerAutoScaling{
+				MinInstancesPerPop: "2", MaxInstancesPerPop: "5",
+			}},
+			expected: coxedge.LoadBalancerSpec{Specs: coxedge.SpecSP1, AutoScaling: &coxedge.LoadBalancerAutoScaling{
+				MinInstancesPerPop: "2", MaxInstancesPerPop: "5", CPUUtilization: 50,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var spec coxedge.LoadBalancerSpec
+			setLoadBalancerDeployment(&spec, &tt.lbSpec)
+			if !reflect.DeepEqual(spec, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, spec)
+			}
+		})
+	}
+}
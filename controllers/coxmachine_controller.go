@@ -21,7 +21,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -29,22 +31,29 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/cluster-api/util"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	coxv1 "github.com/coxedge/cluster-api-provider-cox/api/v1beta1"
 	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge/poller"
 	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge/scope"
 	"github.com/go-logr/logr"
 )
@@ -52,6 +61,9 @@ import (
 var (
 	errWorkloadDeploymentInProgress = errors.New("machine deployment is still in progress")
 	errWorkloadDeploymentNotFound   = errors.New("machine deployment has not been started")
+	errWorkloadDeploymentFailed     = errors.New("machine deployment failed")
+	errWorkloadCreationPending      = errors.New("requested machine workload is not visible yet")
+	errWorkloadRequestUnresolved    = errors.New("requested machine workload has not been found")
 )
 
 const (
@@ -59,7 +71,16 @@ const (
 )
 
 const (
+	// CoxMachineReadyCondition used to report the readiness of the machine.
+	//
+	// Deprecated: the readiness of the machine is reported by the Ready
+	// condition, a summary of the provisioning conditions below. It is removed
+	// from existing machines.
 	CoxMachineReadyCondition clusterv1.ConditionType = "CoxMachineReady"
+
+	// BootstrapDataAvailableCondition reports whether the bootstrap data of
+	// the machine is available.
+	BootstrapDataAvailableCondition clusterv1.ConditionType = "BootstrapDataAvailable"
 	// ClusterNotFoundReason used when the machine is missing the cluster
 	ClusterNotFoundReason = "ClusterNotFound"
 	// ClusterInfrastructureNotReadyReason used when the InfrastractureReady status is false
@@ -68,16 +89,87 @@ const (
 	BootstrapNotAvailableReason = "BootstrapNotAvailable"
 	// BootstrapDataNotFoundReason used when MachineScope fails to get Bootstrap data
 	BootstrapDataNotFoundReason = "BootstrapDataNotFound"
-	// MachineErroredStateReason used when CoxMachine enters errored state
-	MachineErroredStateReason = "MachineErroredState"
+	// AdditionalUserDataNotFoundReason used when MachineScope fails to get the additional user data
+	AdditionalUserDataNotFoundReason = "AdditionalUserDataNotFound"
+	// InvalidUserDataReason used when the additional user data cannot be merged with the bootstrap data
+	InvalidUserDataReason = "InvalidUserData"
+
+	// WorkloadCreatedCondition reports whether the Cox Edge workload of the
+	// machine has been created.
+	WorkloadCreatedCondition clusterv1.ConditionType = "WorkloadCreated"
 	// WorkloadCreateFailedReason used when CoxClient fails to create a Workload
 	WorkloadCreateFailedReason = "WorkloadCreateFailed"
 	// FailedWorkloadReconcileReason used when failing to set ProviderID and Workload failes to reconcile
 	FailedWorkloadReconcileReason = "FailedWorkloadReconcile"
-	// InstanceNotReady used when the instance is not ready yet
+	// WaitingForWorkloadReason used while a requested workload is not visible yet
+	WaitingForWorkloadReason = "WaitingForWorkload"
+	// WorkloadRequestUnresolvedReason used when a requested workload has not been found within the grace period
+	WorkloadRequestUnresolvedReason = "WorkloadRequestUnresolved"
+	// WorkloadNotFoundReason used when the workload of a provisioned machine no longer exists
+	WorkloadNotFoundReason = "WorkloadNotFound"
+
+	// TaskSucceededCondition reports whether the provisioning task of the
+	// workload of the machine succeeded.
+	TaskSucceededCondition clusterv1.ConditionType = "TaskSucceeded"
+	// TaskInProgressReason used while the provisioning task of the workload is running
+	TaskInProgressReason = "TaskInProgress"
+	// TaskFailedReason used when the provisioning task of the workload failed
+	TaskFailedReason = "TaskFailed"
+
+	// LoadBalancerDrainedCondition reports whether a deleting machine has been
+	// removed from the backends of the load balancers routing to it.
+	LoadBalancerDrainedCondition clusterv1.ConditionType = "LoadBalancerDrained"
+	// DrainingLoadBalancerReason used while the load balancers still route to a deleting machine
+	DrainingLoadBalancerReason = "DrainingLoadBalancer"
+
+	// InstanceRunningCondition reports the lifecycle state of the Cox Edge
+	// instance of the machine.
+	InstanceRunningCondition clusterv1.ConditionType = "InstanceRunning"
+	// InstanceProvisioningReason used while the instance is being scheduled or started
+	InstanceProvisioningReason = "InstanceProvisioning"
+	// InstanceStoppedReason used when the instance is stopping or stopped
+	InstanceStoppedReason = "InstanceStopped"
+	// InstanceFailedReason used when the instance failed
+	InstanceFailedReason = "InstanceFailed"
+	// InstanceMissingReason used while the instance of a provisioned machine is missing or being deleted
+	InstanceMissingReason = "InstanceMissing"
+	// InstanceDeletedReason used when the instance or workload was deleted outside of the controller
+	InstanceDeletedReason = "InstanceDeleted"
+	// InstanceNotReady used when the instances of the workload could not be retrieved
 	InstanceNotReady = "InstanceNotReady"
+
+	// AddressesAssignedCondition reports whether the instance of the machine
+	// has been assigned addresses.
+	AddressesAssignedCondition clusterv1.ConditionType = "AddressesAssigned"
+	// WaitingForAddressesReason used while the instance does not have any address
+	WaitingForAddressesReason = "WaitingForAddresses"
+
+	// NodeLinkedCondition reports whether the Node of the machine in the
+	// workload cluster has been given the provider ID of the machine.
+	NodeLinkedCondition clusterv1.ConditionType = "NodeLinked"
+	// NodeNotFoundReason used when no Node of the workload cluster matches the machine
+	NodeNotFoundReason = "NodeNotFound"
+	// NodeLinkFailedReason used when the Nodes of the workload cluster could not be listed or patched
+	NodeLinkFailedReason = "NodeLinkFailed"
 )
 
+// loadBalancerDrainTimeout bounds how long the deletion of a machine waits for
+// the load balancers to stop routing to it, so that a load balancer that
+// cannot be updated does not block the deletion forever.
+const loadBalancerDrainTimeout = 5 * time.Minute
+
+// workloadCreateGracePeriod is how long a requested workload that could not
+// be found is expected to show up within, since the workloads listed by Cox
+// Edge lag behind their creation. Beyond it, the machine keeps looking for the
+// workload but reports it as unresolved.
+const workloadCreateGracePeriod = 5 * time.Minute
+
+// instanceLostGracePeriod is how long the instance of a provisioned machine
+// has to be missing or being deleted before the machine is failed, like the
+// timeout of the unhealthy conditions of a MachineHealthCheck, so that a
+// short gap in the listings of Cox Edge does not fail a healthy machine.
+const instanceLostGracePeriod = 5 * time.Minute
+
 // CoxMachineReconciler reconciles a CoxMachine object
 type CoxMachineReconciler struct {
 	client.Client
@@ -85,13 +177,32 @@ type CoxMachineReconciler struct {
 	Recorder           record.EventRecorder
 	DefaultCredentials *scope.Credentials
 	Tracker            *remote.ClusterCacheTracker
+	// DrainDelay is the time to wait between removing a deleting machine
+	// from the load balancer backends and deleting its workload, allowing
+	// in-flight connections to complete.
+	DrainDelay time.Duration
+	// RequeuePolicy determines when objects waiting on Cox Edge and ready
+	// objects are reconciled again.
+	RequeuePolicy RequeuePolicy
+	// Poller shares the state of the workloads and instances of Cox Edge
+	// between the machines and triggers their reconciliation when it changes.
+	// Without a poller, every machine polls the Cox Edge API.
+	Poller *poller.Poller
+	// ClientFactory creates the Cox Edge clients, rate limited per set of
+	// credentials.
+	ClientFactory *coxedge.ClientFactory
+	// MaxConcurrentReconciles is the number of CoxMachines reconciled
+	// concurrently.
+	MaxConcurrentReconciles int
 }
 
 // +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
 // +kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=coxmachines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=coxmachines/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=coxloadbalancers,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -129,6 +240,11 @@ func (r *CoxMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		logger.Info("Machine is missing cluster label or cluster does not exist")
 		return ctrl.Result{}, nil
 	}
+	if annotations.IsPaused(cluster, coxMachine) {
+		logger.Info("CoxMachine or linked Cluster is marked as paused. Won't reconcile")
+		return ctrl.Result{}, nil
+	}
+
 	coxCluster := &coxv1.CoxCluster{}
 	coxClusterName := client.ObjectKey{
 		Namespace: coxMachine.Namespace,
@@ -152,6 +268,7 @@ func (r *CoxMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		Machine:            machine,
 		DefaultCredentials: r.DefaultCredentials,
 		Tracker:            r.Tracker,
+		ClientFactory:      r.ClientFactory,
 	})
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to create scope: %w", err)
@@ -159,6 +276,7 @@ func (r *CoxMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	// Always close the scope when exiting this function so we can persist any CoxMachine changes.
 	defer func() {
+		setMachineSummary(coxMachine)
 		if err := machineScope.Close(); err != nil && reterr == nil {
 			reterr = err
 		}
@@ -166,7 +284,11 @@ func (r *CoxMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	// Handle deleted machines
 	if !coxMachine.ObjectMeta.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(ctx, machineScope, logger)
+		result, err := r.reconcileDelete(ctx, machineScope, logger)
+		if !controllerutil.ContainsFinalizer(coxMachine, coxv1.MachineFinalizer) {
+			r.Poller.View(machineScope.CoxClient).Untrack(coxMachine)
+		}
+		return result, err
 	}
 
 	return r.reconcileNormal(ctx, machineScope, logger)
@@ -175,7 +297,7 @@ func (r *CoxMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 func (r *CoxMachineReconciler) reconcileNormal(ctx context.Context, machineScope *scope.MachineScope, logger logr.Logger) (ctrl.Result, error) {
 	logger.Info("Reconciling CoxMachine")
 	coxMachine := machineScope.CoxMachine
-	conditions.MarkUnknown(coxMachine, CoxMachineReadyCondition, "", "")
+	conditions.Delete(coxMachine, CoxMachineReadyCondition)
 
 	// Add the finalizer to the CoxMachine if it does not exist yet.
 	controllerutil.AddFinalizer(coxMachine, coxv1.MachineFinalizer)
@@ -185,10 +307,10 @@ func (r *CoxMachineReconciler) reconcileNormal(ctx context.Context, machineScope
 		cluster, err := util.GetClusterFromMetadata(ctx, r.Client, machineScope.Machine.ObjectMeta)
 		if err != nil {
 			logger.Info("Machine is missing cluster label or cluster does not exist")
-			conditions.MarkFalse(coxMachine, CoxMachineReadyCondition, ClusterNotFoundReason, clusterv1.ConditionSeverityInfo, "Machine is missing cluster label or cluster does not exist")
+			conditions.MarkFalse(coxMachine, WorkloadCreatedCondition, ClusterNotFoundReason, clusterv1.ConditionSeverityInfo, "Machine is missing cluster label or cluster does not exist")
 			return ctrl.Result{}, nil
 		}
-		conditions.MarkFalse(coxMachine, CoxMachineReadyCondition, ClusterNotFoundReason, clusterv1.ConditionSeverityInfo, err.Error())
+		conditions.MarkFalse(coxMachine, WorkloadCreatedCondition, ClusterNotFoundReason, clusterv1.ConditionSeverityInfo, err.Error())
 		return reconcile.Result{}, apierrors.NewNotFound(
 			coxv1.GroupVersion.WithResource("coxclusters").GroupResource(),
 			cluster.Spec.InfrastructureRef.Name,
@@ -198,35 +320,76 @@ func (r *CoxMachineReconciler) reconcileNormal(ctx context.Context, machineScope
 	// Make sure that the cluster infrastructure is ready.
 	if !machineScope.Cluster.Status.InfrastructureReady {
 		machineScope.Info("Cluster infrastructure is not ready yet")
-		conditions.MarkFalse(coxMachine, CoxMachineReadyCondition, ClusterInfrastructureNotReadyReason, clusterv1.ConditionSeverityInfo, "Cluster infrastructure is not ready yet")
+		conditions.MarkFalse(coxMachine, WorkloadCreatedCondition, ClusterInfrastructureNotReadyReason, clusterv1.ConditionSeverityInfo, "Cluster infrastructure is not ready yet")
 		return reconcile.Result{}, nil
 	}
 
 	// Make sure that bootstrap data is available and populated.
 	if machineScope.Machine.Spec.Bootstrap.DataSecretName == nil {
 		machineScope.Info("Bootstrap data secret reference is not yet available")
-		conditions.MarkFalse(coxMachine, CoxMachineReadyCondition, BootstrapNotAvailableReason, clusterv1.ConditionSeverityInfo, "Bootstrap data secret reference is not yet available")
+		conditions.MarkFalse(coxMachine, BootstrapDataAvailableCondition, BootstrapNotAvailableReason, clusterv1.ConditionSeverityInfo, "Bootstrap data secret reference is not yet available")
 		return reconcile.Result{}, nil
 	}
+	conditions.MarkTrue(coxMachine, BootstrapDataAvailableCondition)
 
-	// If the CoxMachine is in an error state, return early.
+	// Machines failed by older versions only report the deprecated error
+	// message, which was only set for workloads that no longer exist.
 	if coxMachine.Status.ErrorMessage != nil {
-		machineScope.Info("Error state detected, skipping reconciliation")
-		conditions.MarkFalse(coxMachine, CoxMachineReadyCondition, MachineErroredStateReason, clusterv1.ConditionSeverityInfo, *coxMachine.Status.ErrorMessage)
-		return ctrl.Result{}, fmt.Errorf(*coxMachine.Status.ErrorMessage)
+		if !machineScope.HasFailed() {
+			machineScope.SetFailureReason(capierrors.UpdateMachineError)
+			coxMachine.Status.FailureMessage = coxMachine.Status.ErrorMessage
+		}
+		coxMachine.Status.ErrorMessage = nil
+	}
+
+	// A terminal failure is never retried; the Machine has to be remediated
+	// by replacing it, for example by a MachineHealthCheck.
+	if machineScope.HasFailed() {
+		machineScope.Info("Terminal failure detected, skipping reconciliation", "message", pointer.StringDeref(coxMachine.Status.FailureMessage, ""))
+		coxMachine.Status.Ready = false
+		return ctrl.Result{}, nil
 	}
 
 	// Set the ProviderID if the CoxMachine is already present=
 	err := r.reconcileWorkload(machineScope)
 	if err != nil {
 		switch err {
-		case errWorkloadDeploymentNotFound, coxedge.ErrWorkloadNotFound:
+		case coxedge.ErrWorkloadNotFound:
+			// A workload that existed is never recreated; the Machine has to be replaced instead.
+			machineScope.SetFailureReason(capierrors.UpdateMachineError)
+			machineScope.SetFailureMessage(fmt.Errorf("workload %s of the machine no longer exists", machineScope.GetWorkloadID()))
+			coxMachine.Status.Ready = false
+			conditions.MarkFalse(coxMachine, InstanceRunningCondition, InstanceDeletedReason, clusterv1.ConditionSeverityError, "Workload %s no longer exists", machineScope.GetWorkloadID())
+			conditions.MarkFalse(coxMachine, WorkloadCreatedCondition, WorkloadNotFoundReason, clusterv1.ConditionSeverityError, "Workload %s no longer exists", machineScope.GetWorkloadID())
+			return ctrl.Result{}, nil
+		case errWorkloadDeploymentNotFound:
 			logger.Info("No CoxEdge workload found for this machine; creating it.")
 			bootstrapData, err := machineScope.GetRawBootstrapData()
 			if err != nil {
-				conditions.MarkFalse(coxMachine, CoxMachineReadyCondition, BootstrapDataNotFoundReason, clusterv1.ConditionSeverityInfo, err.Error())
+				conditions.MarkFalse(coxMachine, BootstrapDataAvailableCondition, BootstrapDataNotFoundReason, clusterv1.ConditionSeverityInfo, err.Error())
 				return ctrl.Result{}, fmt.Errorf("failed to get bootstrap data: %w", err)
 			}
+			var additionalUserData []string
+			if userData, err := machineScope.GetAdditionalUserData(); err == scope.ErrAmbiguousUserDataSource {
+				// Retrying does not help until the spec of the machine is fixed.
+				machineScope.SetFailureReason(capierrors.InvalidConfigurationMachineError)
+				machineScope.SetFailureMessage(fmt.Errorf("invalid additional user data: %w", err))
+				conditions.MarkFalse(coxMachine, BootstrapDataAvailableCondition, InvalidUserDataReason, clusterv1.ConditionSeverityError, err.Error())
+				return ctrl.Result{}, nil
+			} else if err != nil {
+				conditions.MarkFalse(coxMachine, BootstrapDataAvailableCondition, AdditionalUserDataNotFoundReason, clusterv1.ConditionSeverityWarning, err.Error())
+				return ctrl.Result{}, fmt.Errorf("failed to get additional user data: %w", err)
+			} else if len(userData) > 0 {
+				additionalUserData = append(additionalUserData, userData)
+			}
+			userData, err := coxedge.MergeUserData(bootstrapData, additionalUserData...)
+			if err != nil {
+				// The user data of a machine cannot change, so it has to be replaced.
+				machineScope.SetFailureReason(capierrors.InvalidConfigurationMachineError)
+				machineScope.SetFailureMessage(fmt.Errorf("invalid user data: %w", err))
+				conditions.MarkFalse(coxMachine, BootstrapDataAvailableCondition, InvalidUserDataReason, clusterv1.ConditionSeverityError, err.Error())
+				return ctrl.Result{}, nil
+			}
 
 			data := &coxedge.CreateWorkloadRequest{
 				Name:                machineScope.Name(),
@@ -235,7 +398,11 @@ func (r *CoxMachineReconciler) reconcileNormal(ctx context.Context, machineScope
 				AddAnyCastIPAddress: machineScope.CoxMachine.Spec.AddAnyCastIPAddress,
 				FirstBootSSHKey:     strings.Join(machineScope.CoxMachine.Spec.SSHAuthorizedKeys, "\n"),
 				Specs:               machineScope.CoxMachine.Spec.Specs,
-				UserData:            bootstrapData,
+				UserData:            userData,
+				EnvironmentVariables: []coxedge.EnvironmentVariable{{
+					Key:   coxedge.EnvKeyWorkloadNonce,
+					Value: workloadNonce(coxMachine),
+				}},
 			}
 
 			for _, port := range machineScope.CoxMachine.Spec.Ports {
@@ -257,7 +424,7 @@ func (r *CoxMachineReconciler) reconcileNormal(ctx context.Context, machineScope
 			}
 
 			data.Deployments = []coxedge.Deployment{}
-			for _, deployment := range machineScope.CoxMachine.Spec.Deployments {
+			for _, deployment := range machineDeployments(machineScope.CoxMachine, machineScope.Machine.Spec.FailureDomain) {
 				d := coxedge.Deployment{
 					Name:               deployment.Name,
 					Pops:               deployment.Pops,
@@ -270,19 +437,36 @@ func (r *CoxMachineReconciler) reconcileNormal(ctx context.Context, machineScope
 				data.Deployments = append(data.Deployments, d)
 			}
 
-			data.NetworkInterfaces = []coxedge.NetworkInterface{}
-			n := coxedge.NetworkInterface{
-				VPCSlug:    "default",
-				SubnetSlug: "",
-				IPFamilies: "IPv4",
-				IsPublicIP: true,
+			data.NetworkInterfaces = networkInterfaces(machineScope.CoxMachine.Spec.NetworkInterfaces, machineScope.CoxCluster.Spec.Network)
+
+			// Persist the intent to create the workload before requesting it,
+			// so that it is adopted rather than created again if the task ID
+			// of its creation is lost.
+			annotations.AddAnnotations(coxMachine, map[string]string{
+				coxv1.WorkloadRequestedAnnotation: time.Now().UTC().Format(time.RFC3339),
+			})
+			if err := machineScope.PatchObject(ctx); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to record the workload request: %w", err)
 			}
-			data.NetworkInterfaces = append(data.NetworkInterfaces, n)
 
 			resp, err := machineScope.CoxClient.CreateWorkload(data)
 			if err != nil {
-				conditions.MarkFalse(coxMachine, CoxMachineReadyCondition, WorkloadCreateFailedReason, clusterv1.ConditionSeverityInfo, err.Error())
 				errResp := &coxedge.HTTPError{}
+				reason, terminal := workloadCreateFailureReason(err)
+				if terminal {
+					// The workload was rejected, so no workload has been
+					// created. Any other failure, like a server error or a
+					// timeout, may still have created it.
+					delete(coxMachine.Annotations, coxv1.WorkloadRequestedAnnotation)
+
+					// Retrying a rejected spec or image cannot succeed.
+					machineScope.SetFailureReason(reason)
+					machineScope.SetFailureMessage(fmt.Errorf("failed to create workload: %w", err))
+					conditions.MarkFalse(coxMachine, WorkloadCreatedCondition, WorkloadCreateFailedReason, clusterv1.ConditionSeverityError, err.Error())
+					r.Recorder.Eventf(machineScope.CoxMachine, corev1.EventTypeWarning, "CreatingWorkloadFailed", "Failed to create workload for machine %s: %v", machineScope.Machine.Name, err)
+					return ctrl.Result{}, nil
+				}
+				conditions.MarkFalse(coxMachine, WorkloadCreatedCondition, WorkloadCreateFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 				if errors.As(err, &errResp) {
 					jsn, _ := json.Marshal(errResp)
 					r.Recorder.Eventf(machineScope.CoxMachine, corev1.EventTypeNormal, "CreatingWorkloadFailed", "Failed to create machine '%s`:`%s`", machineScope.Machine.Name, machineScope.Machine.UID, string(jsn))
@@ -296,66 +480,235 @@ func (r *CoxMachineReconciler) reconcileNormal(ctx context.Context, machineScope
 
 			// Since the workload has just been created we have to requeue and poll for provisioning status with task ID
 			machineScope.CoxMachine.Status.TaskID = resp.TaskID
+			conditions.MarkTrue(coxMachine, WorkloadCreatedCondition)
+			conditions.MarkFalse(coxMachine, TaskSucceededCondition, TaskInProgressReason, clusterv1.ConditionSeverityInfo, "Provisioning task %s has been started", resp.TaskID)
 			return ctrl.Result{}, nil
+		case errWorkloadDeploymentFailed:
+			machineScope.SetFailureReason(capierrors.CreateMachineError)
+			machineScope.SetFailureMessage(fmt.Errorf("provisioning task %s of the workload failed", coxMachine.Status.TaskID))
+			conditions.MarkTrue(coxMachine, WorkloadCreatedCondition)
+			conditions.MarkFalse(coxMachine, TaskSucceededCondition, TaskFailedReason, clusterv1.ConditionSeverityError, "Provisioning task %s of the workload failed", coxMachine.Status.TaskID)
+			return ctrl.Result{}, nil
+		case errWorkloadCreationPending:
+			logger.Info("Waiting for the requested workload to become visible before requesting it again")
+			conditions.MarkFalse(coxMachine, WorkloadCreatedCondition, WaitingForWorkloadReason, clusterv1.ConditionSeverityInfo, "Waiting for the workload requested at %s", coxMachine.Annotations[coxv1.WorkloadRequestedAnnotation])
+			return r.RequeuePolicy.WaitingOn(coxMachine, WorkloadCreatedCondition), nil
+		case errWorkloadRequestUnresolved:
+			// Requesting the workload again could run a second workload for
+			// the machine, so it is left to an operator to do so.
+			logger.Info("The requested workload has not been found yet; still waiting for it")
+			conditions.MarkFalse(coxMachine, WorkloadCreatedCondition, WorkloadRequestUnresolvedReason, clusterv1.ConditionSeverityWarning,
+				"The workload requested at %s has not been found; remove the %s annotation to request it again", coxMachine.Annotations[coxv1.WorkloadRequestedAnnotation], coxv1.WorkloadRequestedAnnotation)
+			return r.RequeuePolicy.WaitingOn(coxMachine, WorkloadCreatedCondition), nil
 		case errWorkloadDeploymentInProgress:
-			return ctrl.Result{
-				// Requeue until the machine is ready
-				RequeueAfter: 1 * time.Minute,
-			}, nil
+			conditions.MarkTrue(coxMachine, WorkloadCreatedCondition)
+			conditions.MarkFalse(coxMachine, TaskSucceededCondition, TaskInProgressReason, clusterv1.ConditionSeverityInfo, "Provisioning task %s is %s", coxMachine.Status.TaskID, strings.ToLower(coxMachine.Status.TaskStatus))
+			// Requeue until the machine is ready
+			return r.RequeuePolicy.WaitingOn(coxMachine, TaskSucceededCondition), nil
 		default:
-			conditions.MarkFalse(coxMachine, CoxMachineReadyCondition, FailedWorkloadReconcileReason, clusterv1.ConditionSeverityInfo, err.Error())
+			if !conditions.IsTrue(coxMachine, WorkloadCreatedCondition) {
+				conditions.MarkFalse(coxMachine, WorkloadCreatedCondition, FailedWorkloadReconcileReason, clusterv1.ConditionSeverityWarning, err.Error())
+			}
 			return ctrl.Result{}, fmt.Errorf("error while reconciling workload: %w", err)
 		}
 	}
+	conditions.MarkTrue(coxMachine, WorkloadCreatedCondition)
+	conditions.MarkTrue(coxMachine, TaskSucceededCondition)
 
 	workloadID := machineScope.GetWorkloadID()
+	view := r.Poller.View(machineScope.CoxClient)
+	view.Track(workloadID, coxMachine)
+
 	logger.Info("Checking the workload's instance status", "workloadID", workloadID)
-	instances, err := machineScope.CoxClient.GetInstances(workloadID)
+	instances, ok := view.Instances(workloadID)
+	if !ok || instanceLost(instances) {
+		// The view may be stale, so a lost instance is confirmed through the API.
+		resp, err := machineScope.CoxClient.GetInstances(workloadID)
+		if err != nil {
+			conditions.MarkFalse(coxMachine, InstanceRunningCondition, InstanceNotReady, clusterv1.ConditionSeverityWarning, err.Error())
+			return ctrl.Result{}, err
+		}
+		instances = resp.Data
+	}
+	var instance *coxedge.InstanceData
+	if len(instances) > 0 {
+		// For a CoxMachine we currently just assume 1 CAPI Machine == 1 Cox Workload == 1 Cox Instance
+		instance = &instances[0]
+	}
+	if !reconcileInstanceStatus(machineScope, instance) {
+		if machineScope.HasFailed() {
+			r.Recorder.Eventf(coxMachine, corev1.EventTypeWarning, "InstanceLost", "Instance of machine %s was lost: %s", machineScope.Machine.Name, *coxMachine.Status.FailureMessage)
+			return ctrl.Result{}, nil
+		}
+		logger.Info("Instance not running yet", "status", conditions.GetReason(coxMachine, InstanceRunningCondition))
+		return r.RequeuePolicy.WaitingOn(coxMachine, InstanceRunningCondition), nil
+	}
+
+	machineScope.SetAddresses(instanceAddresses(instance))
+	machineScope.CoxMachine.Status.InstanceID = instance.ID
+	if len(coxMachine.Status.Addresses) == 0 {
+		conditions.MarkFalse(coxMachine, AddressesAssignedCondition, WaitingForAddressesReason, clusterv1.ConditionSeverityInfo, "Instance %s does not have any address yet", instance.Name)
+	} else {
+		conditions.MarkTrue(coxMachine, AddressesAssignedCondition)
+	}
+
+	linked, err := machineScope.SetNodeProviderID(ctx)
 	if err != nil {
-		conditions.MarkFalse(coxMachine, CoxMachineReadyCondition, InstanceNotReady, clusterv1.ConditionSeverityInfo, err.Error())
+		conditions.MarkFalse(coxMachine, NodeLinkedCondition, NodeLinkFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 		return ctrl.Result{}, err
 	}
-	if len(instances.Data) == 0 {
-		logger.Info("Instance not deployed yet.")
-		conditions.MarkFalse(coxMachine, CoxMachineReadyCondition, InstanceNotReady, clusterv1.ConditionSeverityInfo, "Instance not deployed yet.")
-		return ctrl.Result{
-			RequeueAfter: 1 * time.Minute,
-		}, nil
+
+	machineScope.CoxMachine.Status.Ready = true
+	if !linked {
+		logger.Info("No Node of the workload cluster matches the machine yet")
+		conditions.MarkFalse(coxMachine, NodeLinkedCondition, NodeNotFoundReason, clusterv1.ConditionSeverityInfo, "No Node of the workload cluster matches the machine")
+		return r.RequeuePolicy.WaitingOn(coxMachine, NodeLinkedCondition), nil
 	}
-	// For a CoxMachine we currently just assume 1 CAPI Machine == 1 Cox Workload == 1 Cox Instance
-	instance := instances.Data[0]
+	conditions.MarkTrue(coxMachine, NodeLinkedCondition)
+	// Requeue to make sure that the CoxMachine controller detects when the VM died on CoxEdge
+	return r.RequeuePolicy.Synced(), nil
+}
 
-	// It can happen that an instance is stuck in SCHEDULING for a longer time.
-	if instance.Status != "RUNNING" {
-		logger.Info("Instance not ready yet.")
-		return ctrl.Result{
-			RequeueAfter: 1 * time.Minute,
-		}, nil
+// setMachineSummary sets the Ready condition of the machine to a summary of
+// the conditions of its provisioning steps. The last transition time of each
+// step condition records when the step completed or got stuck.
+func setMachineSummary(coxMachine *coxv1.CoxMachine) {
+	steps := []clusterv1.ConditionType{
+		BootstrapDataAvailableCondition,
+		WorkloadCreatedCondition,
+		TaskSucceededCondition,
+		InstanceRunningCondition,
+		AddressesAssignedCondition,
+		NodeLinkedCondition,
 	}
+	deleting := !coxMachine.ObjectMeta.DeletionTimestamp.IsZero()
+	if deleting {
+		steps = append(steps, LoadBalancerDrainedCondition)
+	}
+	conditions.SetSummary(coxMachine,
+		conditions.WithConditions(steps...),
+		conditions.WithStepCounterIf(!deleting),
+	)
+}
 
-	machineScope.SetAddresses([]corev1.NodeAddress{
-		{
-			Type:    corev1.NodeExternalIP,
-			Address: instance.PublicIPAddress,
-		},
-		{
-			Type:    corev1.NodeInternalIP,
-			Address: instance.IPAddress[0],
-		},
-	})
+// instanceLost returns whether the instances of a workload are missing,
+// failed or being deleted.
+func instanceLost(instances []coxedge.InstanceData) bool {
+	if len(instances) == 0 {
+		return true
+	}
+	switch instances[0].Status {
+	case coxedge.InstanceStatusFailed, coxedge.InstanceStatusDeleting:
+		return true
+	}
+	return false
+}
 
-	conditions.MarkTrue(machineScope.CoxMachine, CoxMachineReadyCondition)
+// reconcileInstanceStatus maps the lifecycle state of the instance of a
+// machine to its conditions and returns whether the instance is running.
+// An instance that failed, or that stays missing or deleted outside of the
+// controller for instanceLostGracePeriod after the machine got one, is a
+// terminal failure, so that the Machine is replaced instead of leaving a node
+// without an instance behind. A stopped instance only makes the machine not
+// ready, since it can be started again.
+func reconcileInstanceStatus(machineScope *scope.MachineScope, instance *coxedge.InstanceData) bool {
+	coxMachine := machineScope.CoxMachine
+	provisioned := coxMachine.Status.Ready || len(coxMachine.Status.InstanceID) > 0
 
-	err = machineScope.SetNodeProviderID()
-	if err != nil {
-		return ctrl.Result{}, err
+	status := coxedge.InstanceStatusDeleting
+	if instance != nil {
+		status = instance.Status
+	} else if !provisioned {
+		conditions.MarkFalse(coxMachine, InstanceRunningCondition, InstanceProvisioningReason, clusterv1.ConditionSeverityInfo, "Instance not deployed yet")
+		return false
 	}
 
-	machineScope.CoxMachine.Status.Ready = true
-	return ctrl.Result{
-		// Requeue to make sure that the CoxMachine controller detects when the VM died on CoxEdge
-		RequeueAfter: 5 * time.Minute,
-	}, nil
+	switch status {
+	case coxedge.InstanceStatusRunning:
+		conditions.MarkTrue(coxMachine, InstanceRunningCondition)
+		return true
+	case coxedge.InstanceStatusStopping, coxedge.InstanceStatusStopped:
+		coxMachine.Status.Ready = false
+		conditions.MarkFalse(coxMachine, InstanceRunningCondition, InstanceStoppedReason, clusterv1.ConditionSeverityWarning, "Instance is %s", strings.ToLower(status))
+	case coxedge.InstanceStatusFailed:
+		reason := capierrors.CreateMachineError
+		if provisioned {
+			reason = capierrors.UpdateMachineError
+		}
+		machineScope.SetFailureReason(reason)
+		machineScope.SetFailureMessage(fmt.Errorf("instance %s of the machine failed", instance.Name))
+		coxMachine.Status.Ready = false
+		conditions.MarkFalse(coxMachine, InstanceRunningCondition, InstanceFailedReason, clusterv1.ConditionSeverityError, "Instance %s failed", instance.Name)
+	case coxedge.InstanceStatusDeleting:
+		coxMachine.Status.Ready = false
+		// The message is constant, so that the condition keeps the time the
+		// instance went missing.
+		conditions.MarkFalse(coxMachine, InstanceRunningCondition, InstanceMissingReason, clusterv1.ConditionSeverityWarning, "Instance of workload %s is missing or being deleted", machineScope.GetWorkloadID())
+		if since := conditions.GetLastTransitionTime(coxMachine, InstanceRunningCondition); since != nil && time.Since(since.Time) < instanceLostGracePeriod {
+			break
+		}
+		machineScope.SetFailureReason(capierrors.UpdateMachineError)
+		machineScope.SetFailureMessage(fmt.Errorf("instance of workload %s was deleted", machineScope.GetWorkloadID()))
+		coxMachine.Status.Ready = false
+		conditions.MarkFalse(coxMachine, InstanceRunningCondition, InstanceDeletedReason, clusterv1.ConditionSeverityError, "Instance of workload %s was deleted", machineScope.GetWorkloadID())
+	default:
+		// It can happen that an instance is stuck in SCHEDULING for a longer time.
+		coxMachine.Status.Ready = false
+		conditions.MarkFalse(coxMachine, InstanceRunningCondition, InstanceProvisioningReason, clusterv1.ConditionSeverityInfo, "Instance is %s", strings.ToLower(status))
+	}
+	return false
+}
+
+// workloadCreateFailureReason returns the failure reason of an error creating
+// a workload and whether it is terminal. Workloads rejected for an exceeded
+// quota or an invalid spec or image are not retried; all other errors are
+// assumed to be transient.
+func workloadCreateFailureReason(err error) (capierrors.MachineStatusError, bool) {
+	respErr := &coxedge.HTTPError{}
+	if !errors.As(err, &respErr) {
+		return "", false
+	}
+	// Only rejected requests are terminal; rate limits and server errors
+	// may mention a quota too, but are transient.
+	switch respErr.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		if strings.Contains(strings.ToLower(respErr.Message), "quota") {
+			return capierrors.InsufficientResourcesMachineError, true
+		}
+		return capierrors.InvalidConfigurationMachineError, true
+	}
+	return "", false
+}
+
+// instanceAddresses returns the node addresses of an instance: its public
+// IP, if any, every IPv4 and IPv6 address of its network interfaces, and its
+// name. Addresses are in canonical form, so that they match the addresses
+// reported by the node.
+func instanceAddresses(instance *coxedge.InstanceData) []corev1.NodeAddress {
+	var addresses []corev1.NodeAddress
+	if ip := net.ParseIP(instance.PublicIPAddress); ip != nil {
+		addresses = append(addresses, corev1.NodeAddress{
+			Type:    corev1.NodeExternalIP,
+			Address: ip.String(),
+		})
+	}
+	for _, address := range instance.IPAddress {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			continue
+		}
+		addresses = append(addresses, corev1.NodeAddress{
+			Type:    corev1.NodeInternalIP,
+			Address: ip.String(),
+		})
+	}
+	if len(instance.Name) > 0 {
+		addresses = append(addresses,
+			corev1.NodeAddress{Type: corev1.NodeHostName, Address: instance.Name},
+			corev1.NodeAddress{Type: corev1.NodeInternalDNS, Address: instance.Name},
+		)
+	}
+	return addresses
 }
 
 func (r *CoxMachineReconciler) reconcileDelete(ctx context.Context, machineScope *scope.MachineScope, logger logr.Logger) (ctrl.Result, error) {
@@ -363,16 +716,21 @@ func (r *CoxMachineReconciler) reconcileDelete(ctx context.Context, machineScope
 	err := r.reconcileWorkload(machineScope)
 	if err != nil {
 		switch err {
-		case errWorkloadDeploymentNotFound, coxedge.ErrWorkloadNotFound:
-			logger.Info("Machine does not contain a ProviderID or TaskID; assuming that the machine deployment never started.")
+		case errWorkloadDeploymentNotFound, errWorkloadDeploymentFailed, coxedge.ErrWorkloadNotFound:
+			logger.Info("Machine does not have a workload; assuming that the machine deployment never started or failed.")
 			controllerutil.RemoveFinalizer(machineScope.CoxMachine, coxv1.MachineFinalizer)
 			return ctrl.Result{}, nil
-		case errWorkloadDeploymentInProgress:
+		case errWorkloadRequestUnresolved:
+			// Waiting for a workload that may never have been created would block the deletion forever.
+			nonce := machineScope.CoxMachine.Annotations[coxv1.WorkloadNonceAnnotation]
+			logger.Info("The requested workload has not been found; assuming that it was never created.", "nonce", nonce)
+			r.Recorder.Eventf(machineScope.CoxMachine, corev1.EventTypeWarning, "WorkloadRequestUnresolved", "Deleted machine without its requested workload, which is marked with the %s environment variable %s if it exists", coxedge.EnvKeyWorkloadNonce, nonce)
+			controllerutil.RemoveFinalizer(machineScope.CoxMachine, coxv1.MachineFinalizer)
+			return ctrl.Result{}, nil
+		case errWorkloadDeploymentInProgress, errWorkloadCreationPending:
 			logger.Info("Machine deployment still in progress, waiting for it to complete before deleting the machine.")
-			return ctrl.Result{
-				// Requeue until the machine is ready
-				RequeueAfter: 1 * time.Minute,
-			}, nil
+			// Requeue until the machine is ready
+			return r.RequeuePolicy.Waiting(machineScope.CoxMachine.DeletionTimestamp.Time), nil
 		default:
 			return ctrl.Result{}, err
 		}
@@ -391,6 +749,12 @@ func (r *CoxMachineReconciler) reconcileDelete(ctx context.Context, machineScope
 		return ctrl.Result{}, fmt.Errorf("failed to delete the machine: %v", err)
 	}
 
+	// Stop routing traffic to the machine before its workload goes away.
+	drained, result, err := r.reconcileLoadBalancerDrain(ctx, machineScope, logger)
+	if err != nil || !drained {
+		return result, err
+	}
+
 	logger.Info("Deleting the machine", "workloadID", workloadID)
 	_, err = machineScope.CoxClient.DeleteWorkload(workloadID)
 	if err != nil {
@@ -404,10 +768,134 @@ func (r *CoxMachineReconciler) reconcileDelete(ctx context.Context, machineScope
 	return ctrl.Result{}, nil
 }
 
+// machineDeployments returns the deployments of the workload of the machine.
+// Deployments that do not pin any POPs are placed in the failure domain the
+// Machine has been assigned to.
+func machineDeployments(coxMachine *coxv1.CoxMachine, failureDomain *string) []coxv1.Deployment {
+	if failureDomain == nil || len(*failureDomain) == 0 {
+		return coxMachine.Spec.Deployments
+	}
+	pop := strings.ToUpper(*failureDomain)
+	if len(coxMachine.Spec.Deployments) == 0 {
+		return []coxv1.Deployment{{
+			Name:            "default",
+			Pops:            []string{pop},
+			InstancesPerPop: "1",
+		}}
+	}
+	deployments := make([]coxv1.Deployment, 0, len(coxMachine.Spec.Deployments))
+	for _, deployment := range coxMachine.Spec.Deployments {
+		if len(deployment.Pops) == 0 {
+			deployment.Pops = []string{pop}
+		}
+		deployments = append(deployments, deployment)
+	}
+	return deployments
+}
+
+// reconcileLoadBalancerDrain reports whether the load balancers have stopped
+// routing traffic to the deleting machine. Deleting machines are removed from
+// the backends by the CoxCluster and CoxLoadBalancer controllers; this waits
+// until the load balancers have applied that change, followed by the drain
+// delay, before the workload may be deleted. The load balancers of a deleting
+// cluster are no longer updated and go away with it, so they are not waited
+// for.
+func (r *CoxMachineReconciler) reconcileLoadBalancerDrain(ctx context.Context, machineScope *scope.MachineScope, logger logr.Logger) (bool, ctrl.Result, error) {
+	coxMachine := machineScope.CoxMachine
+	clusterDeleting := !machineScope.Cluster.DeletionTimestamp.IsZero() || !machineScope.CoxCluster.DeletionTimestamp.IsZero()
+	if conditions.IsTrue(coxMachine, LoadBalancerDrainedCondition) {
+		return r.waitForDrainDelay(coxMachine, clusterDeleting)
+	}
+
+	backends, err := r.loadBalancerBackendsForMachine(ctx, coxMachine, clusterDeleting)
+	if err != nil {
+		return false, ctrl.Result{}, fmt.Errorf("failed to list the load balancers of the machine: %w", err)
+	}
+	if len(backends) == 0 {
+		conditions.MarkTrue(coxMachine, LoadBalancerDrainedCondition)
+		return r.waitForDrainDelay(coxMachine, clusterDeleting)
+	}
+
+	if !conditions.Has(coxMachine, LoadBalancerDrainedCondition) {
+		r.Recorder.Eventf(coxMachine, corev1.EventTypeNormal, "DrainingLoadBalancer", "Waiting for the load balancers to stop routing to machine '%s'", machineScope.Machine.Name)
+	}
+	conditions.MarkFalse(coxMachine, LoadBalancerDrainedCondition, DrainingLoadBalancerReason, clusterv1.ConditionSeverityInfo, "Load balancers are still routing to %s", strings.Join(backends, ", "))
+	if time.Since(conditions.GetLastTransitionTime(coxMachine, LoadBalancerDrainedCondition).Time) > loadBalancerDrainTimeout {
+		logger.Info("Timed out waiting for the load balancers to stop routing to the machine; deleting it anyway.", "backends", backends)
+		return true, ctrl.Result{}, nil
+	}
+	logger.Info("Waiting for the load balancers to stop routing to the machine.", "backends", backends)
+	return false, r.RequeuePolicy.WaitingOn(coxMachine, LoadBalancerDrainedCondition), nil
+}
+
+// waitForDrainDelay reports whether the drain delay has passed since the
+// machine was removed from the load balancer backends. Machines that never
+// got an address have not served any traffic and machines of a deleting
+// cluster do not serve traffic anymore, so they are not delayed.
+func (r *CoxMachineReconciler) waitForDrainDelay(coxMachine *coxv1.CoxMachine, clusterDeleting bool) (bool, ctrl.Result, error) {
+	if r.DrainDelay <= 0 || len(coxMachine.Status.Addresses) == 0 || clusterDeleting {
+		return true, ctrl.Result{}, nil
+	}
+	remaining := r.DrainDelay - time.Since(conditions.GetLastTransitionTime(coxMachine, LoadBalancerDrainedCondition).Time)
+	if remaining > 0 {
+		return false, ctrl.Result{RequeueAfter: remaining}, nil
+	}
+	return true, ctrl.Result{}, nil
+}
+
+// loadBalancerBackendsForMachine returns the backends that still route to one
+// of the addresses of the machine, according to the observed state of the
+// load balancers of its cluster, unless the cluster is deleting, and those
+// selecting it as a backend.
+func (r *CoxMachineReconciler) loadBalancerBackendsForMachine(ctx context.Context, coxMachine *coxv1.CoxMachine, clusterDeleting bool) ([]string, error) {
+	addresses := map[string]bool{}
+	for _, addr := range coxMachine.Status.Addresses {
+		addresses[addr.Address] = true
+	}
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	lbList := &coxv1.CoxLoadBalancerList{}
+	if err := r.List(ctx, lbList, client.InNamespace(coxMachine.Namespace)); err != nil {
+		return nil, err
+	}
+	var backends []string
+	for _, lb := range lbList.Items {
+		if !lb.DeletionTimestamp.IsZero() {
+			continue
+		}
+		clusterName, ok := coxMachine.Labels[clusterv1.ClusterLabelName]
+		routesToMachine := ok && lb.Labels[clusterv1.ClusterLabelName] == clusterName
+		if routesToMachine && clusterDeleting {
+			continue
+		}
+		if !routesToMachine && lb.Spec.BackendSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(lb.Spec.BackendSelector)
+			routesToMachine = err == nil && selector.Matches(labels.Set(coxMachine.Labels))
+		}
+		if !routesToMachine {
+			continue
+		}
+		for _, backend := range lb.Status.Backends {
+			host, _, err := net.SplitHostPort(backend)
+			if err != nil {
+				host = backend
+			}
+			if addresses[host] {
+				backends = append(backends, backend)
+			}
+		}
+	}
+	sort.Strings(backends)
+	return backends, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *CoxMachineReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
 	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&coxv1.CoxMachine{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		WithEventFilter(predicates.ResourceNotPaused(ctrl.LoggerFrom(ctx))). // don't queue reconcile if resource is paused
 		Watches(
 			&source.Kind{Type: &clusterv1.Machine{}},
@@ -422,6 +910,13 @@ func (r *CoxMachineReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 		return fmt.Errorf("error creating controller: %w", err)
 	}
 
+	if r.Poller != nil {
+		// Reconcile machines as soon as the poller sees their workload change.
+		if err := c.Watch(r.Poller.Source(), &handler.EnqueueRequestForObject{}); err != nil {
+			return fmt.Errorf("failed adding a watch for workload changes: %w", err)
+		}
+	}
+
 	clusterToObjectFunc, err := util.ClusterToObjectsMapper(r.Client, &coxv1.CoxMachineList{}, mgr.GetScheme())
 	if err != nil {
 		return fmt.Errorf("failed to create mapper for Cluster to CoxMachines: %w", err)
@@ -483,19 +978,27 @@ func (r *CoxMachineReconciler) CoxClusterToCoxMachines(ctx context.Context) hand
 //
 // Note: it does not guarantee that thew referenced workload exists.
 func (r *CoxMachineReconciler) reconcileWorkload(machineScope *scope.MachineScope) error {
-	workload, err := machineScope.CoxClient.GetWorkloadByName(machineScope.CoxMachine.Name)
-	if err != nil {
-		if err != coxedge.ErrWorkloadNotFound {
-			return err
+	// Workloads are looked up in the view of the poller first. Workloads
+	// missing from it, for example because they were created since the last
+	// poll, are looked up through the API.
+	view := r.Poller.View(machineScope.CoxClient)
+
+	// Once known, the workload is only ever looked up by the ID persisted in
+	// the ProviderID, so that it cannot be confused with another workload of
+	// the same name, for example after the machine has been moved.
+	if workloadID := machineScope.GetWorkloadID(); len(workloadID) > 0 {
+		if _, ok := view.Workload(workloadID); ok {
+			return nil
 		}
-		if len(machineScope.CoxMachine.Spec.ProviderID) > 0 {
+		_, err := machineScope.CoxClient.GetWorkload(workloadID)
+		respErr := &coxedge.HTTPError{}
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
 			return coxedge.ErrWorkloadNotFound
 		}
+		return err
+	}
 
-		if machineScope.CoxMachine.Status.TaskID == "" {
-			return errWorkloadDeploymentNotFound
-		}
-
+	if machineScope.CoxMachine.Status.TaskID != "" {
 		// If machine is not ready check for provisioning status
 		task, err := machineScope.CoxClient.GetTask(machineScope.CoxMachine.Status.TaskID)
 		if err != nil {
@@ -505,14 +1008,61 @@ func (r *CoxMachineReconciler) reconcileWorkload(machineScope *scope.MachineScop
 
 		switch machineScope.CoxMachine.Status.TaskStatus {
 		case "SUCCESS":
-			machineScope.SetProviderID(task.Data.Result.WorkloadID)
+			setWorkload(machineScope, task.Data.Result.WorkloadID)
 		case "FAILURE":
-			return fmt.Errorf("provisioning of workload failed")
+			return errWorkloadDeploymentFailed
 		default:
 			return errWorkloadDeploymentInProgress
 		}
+		return nil
+	}
+
+	// Without any persisted identifier, adopt a workload that was requested
+	// for the machine before its task ID could be stored. Workloads requested
+	// by older versions are only marked by their name.
+	var workload *coxedge.WorkloadData
+	var err error
+	if nonce, ok := machineScope.CoxMachine.Annotations[coxv1.WorkloadNonceAnnotation]; ok {
+		if workload, ok = view.WorkloadByNonce(nonce); !ok {
+			workload, err = machineScope.CoxClient.GetWorkloadByNonce(nonce, machineScope.CoxMachine.Name)
+		}
 	} else {
-		machineScope.SetProviderID(workload.ID)
+		workload, err = machineScope.CoxClient.GetWorkloadByName(machineScope.CoxMachine.Name)
+	}
+	if err != nil {
+		if err != coxedge.ErrWorkloadNotFound {
+			return err
+		}
+		requestedAt, ok := machineScope.CoxMachine.Annotations[coxv1.WorkloadRequestedAnnotation]
+		if !ok {
+			return errWorkloadDeploymentNotFound
+		}
+		// A requested workload may still show up, so it is never requested again.
+		if requested, err := time.Parse(time.RFC3339, requestedAt); err == nil && time.Since(requested) < workloadCreateGracePeriod {
+			return errWorkloadCreationPending
+		}
+		return errWorkloadRequestUnresolved
 	}
+	setWorkload(machineScope, workload.ID)
 	return nil
 }
+
+// setWorkload sets the workload of a machine once it is known, dropping the
+// markers of its creation.
+func setWorkload(machineScope *scope.MachineScope, workloadID string) {
+	machineScope.SetProviderID(workloadID)
+	delete(machineScope.CoxMachine.Annotations, coxv1.WorkloadNonceAnnotation)
+	delete(machineScope.CoxMachine.Annotations, coxv1.WorkloadRequestedAnnotation)
+}
+
+// workloadNonce returns the nonce the workload of a machine is marked with,
+// generating one the first time the workload is requested. The nonce is kept
+// across requests, so that any workload requested for the machine is found.
+func workloadNonce(coxMachine *coxv1.CoxMachine) string {
+	if nonce, ok := coxMachine.Annotations[coxv1.WorkloadNonceAnnotation]; ok {
+		return nonce
+	}
+	nonce := string(coxMachine.UID) + "-" + util.RandomString(6)
+	annotations.AddAnnotations(coxMachine, map[string]string{coxv1.WorkloadNonceAnnotation: nonce})
+	return nonce
+}
@@ -0,0 +1,533 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	coxv1 "github.com/coxedge/cluster-api-provider-cox/api/v1beta1"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge/scope"
+)
+
+func newTestCoxLoadBalancer(name string, labels map[string]string, selector *metav1.LabelSelector, backends ...string) *coxv1.CoxLoadBalancer {
+	return &coxv1.CoxLoadBalancer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Spec:       coxv1.CoxLoadBalancerResourceSpec{BackendSelector: selector},
+		Status: coxv1.CoxLoadBalancerResourceStatus{
+			CoxLoadBalancerStatus: coxv1.CoxLoadBalancerStatus{Backends: backends},
+		},
+	}
+}
+
+func TestLoadBalancerBackendsForMachine(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := coxv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cluster := map[string]string{clusterv1.ClusterLabelName: "test"}
+	r := &CoxMachineReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			newTestCoxLoadBalancer("test-control-plane", cluster, nil, "203.0.113.1:6443", "203.0.113.2:6443"),
+			newTestCoxLoadBalancer("test-workers", cluster, nil, "10.0.0.2:80"),
+			newTestCoxLoadBalancer("ingress", nil, &metav1.LabelSelector{MatchLabels: map[string]string{"ingress": "true"}}, "10.0.0.1:443"),
+			newTestCoxLoadBalancer("other", map[string]string{clusterv1.ClusterLabelName: "other"}, nil, "10.0.0.1:80"),
+		).Build(),
+	}
+
+	machine := newTestCoxMachine("test-0", map[string]string{clusterv1.ClusterLabelName: "test", "ingress": "true"}, "10.0.0.1")
+	backends, err := r.loadBalancerBackendsForMachine(context.Background(), machine, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(backends, []string{"10.0.0.1:443", "203.0.113.1:6443"}) {
+		t.Errorf("unexpected backends: %v", backends)
+	}
+
+	// The load balancers of a deleting cluster are not waited for.
+	backends, err = r.loadBalancerBackendsForMachine(context.Background(), machine, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(backends, []string{"10.0.0.1:443"}) {
+		t.Errorf("expected only the backends of other load balancers while the cluster is deleting, got %v", backends)
+	}
+
+	// A machine that never got an address is not routed to.
+	machine.Status.Addresses = nil
+	backends, err = r.loadBalancerBackendsForMachine(context.Background(), machine, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backends) != 0 {
+		t.Errorf("expected no backends, got %v", backends)
+	}
+}
+
+func TestMachineDeployments(t *testing.T) {
+	failureDomain := "LAX"
+	coxMachine := &coxv1.CoxMachine{}
+	expected := []coxv1.Deployment{{Name: "default", Pops: []string{"LAX"}, InstancesPerPop: "1"}}
+	if deployments := machineDeployments(coxMachine, &failureDomain); !reflect.DeepEqual(deployments, expected) {
+		t.Errorf("unexpected deployments without a template deployment: %+v", deployments)
+	}
+
+	coxMachine.Spec.Deployments = []coxv1.Deployment{
+		{Name: "pinned", Pops: []string{"ORF"}, InstancesPerPop: "1"},
+		{Name: "unpinned", InstancesPerPop: "1"},
+	}
+	expected = []coxv1.Deployment{
+		{Name: "pinned", Pops: []string{"ORF"}, InstancesPerPop: "1"},
+		{Name: "unpinned", Pops: []string{"LAX"}, InstancesPerPop: "1"},
+	}
+	if deployments := machineDeployments(coxMachine, &failureDomain); !reflect.DeepEqual(deployments, expected) {
+		t.Errorf("unexpected deployments: %+v", deployments)
+	}
+	if len(coxMachine.Spec.Deployments[1].Pops) != 0 {
+		t.Error("expected the CoxMachine spec to be left untouched")
+	}
+
+	if deployments := machineDeployments(coxMachine, nil); !reflect.DeepEqual(deployments, coxMachine.Spec.Deployments) {
+		t.Errorf("expected the template deployments without a failure domain, got %+v", deployments)
+	}
+}
+
+func TestInstanceAddresses(t *testing.T) {
+	public := instanceAddresses(&coxedge.InstanceData{PublicIPAddress: "192.0.2.1", IPAddress: []string{"10.0.0.1"}})
+	expected := []corev1.NodeAddress{
+		{Type: corev1.NodeExternalIP, Address: "192.0.2.1"},
+		{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+	}
+	if !reflect.DeepEqual(public, expected) {
+		t.Errorf("unexpected addresses: %+v", public)
+	}
+
+	private := instanceAddresses(&coxedge.InstanceData{IPAddress: []string{"10.0.0.2"}})
+	if !reflect.DeepEqual(private, []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.2"}}) {
+		t.Errorf("expected only an internal address for a private instance, got %+v", private)
+	}
+
+	dualStack := instanceAddresses(&coxedge.InstanceData{
+		Name:            "machine-0",
+		PublicIPAddress: "192.0.2.1",
+		IPAddress:       []string{"10.0.0.1", "2001:DB8:0:0::1", "invalid"},
+	})
+	expected = []corev1.NodeAddress{
+		{Type: corev1.NodeExternalIP, Address: "192.0.2.1"},
+		{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: corev1.NodeInternalIP, Address: "2001:db8::1"},
+		{Type: corev1.NodeHostName, Address: "machine-0"},
+		{Type: corev1.NodeInternalDNS, Address: "machine-0"},
+	}
+	if !reflect.DeepEqual(dualStack, expected) {
+		t.Errorf("unexpected dual-stack addresses: %+v", dualStack)
+	}
+}
+
+func TestWorkloadCreateFailureReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		reason   capierrors.MachineStatusError
+		terminal bool
+	}{
+		{"invalid spec", &coxedge.HTTPError{StatusCode: http.StatusBadRequest, Message: "invalid image"}, capierrors.InvalidConfigurationMachineError, true},
+		{"quota exceeded", &coxedge.HTTPError{StatusCode: http.StatusUnprocessableEntity, Message: "Quota exceeded for instances"}, capierrors.InsufficientResourcesMachineError, true},
+		{"rate limited by quota", &coxedge.HTTPError{StatusCode: http.StatusTooManyRequests, Message: "API request quota exceeded"}, "", false},
+		{"unavailable quota service", &coxedge.HTTPError{StatusCode: http.StatusServiceUnavailable, Message: "quota service unavailable"}, "", false},
+		{"server error", &coxedge.HTTPError{StatusCode: http.StatusBadGateway}, "", false},
+		{"rate limited", fmt.Errorf("create: %w", &coxedge.HTTPError{StatusCode: http.StatusTooManyRequests}), "", false},
+		{"network error", errors.New("connection refused"), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, terminal := workloadCreateFailureReason(tt.err)
+			if reason != tt.reason || terminal != tt.terminal {
+				t.Errorf("expected (%q, %v), got (%q, %v)", tt.reason, tt.terminal, reason, terminal)
+			}
+		})
+	}
+}
+
+func TestReconcileInstanceStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		provisioned bool
+		instance    *coxedge.InstanceData
+		running     bool
+		reason      string
+		failure     *capierrors.MachineStatusError
+	}{
+		{name: "not deployed yet", reason: InstanceProvisioningReason},
+		{name: "scheduling", instance: &coxedge.InstanceData{Status: coxedge.InstanceStatusScheduling}, reason: InstanceProvisioningReason},
+		{name: "running", provisioned: true, instance: &coxedge.InstanceData{Status: coxedge.InstanceStatusRunning}, running: true},
+		{name: "stopped", provisioned: true, instance: &coxedge.InstanceData{Status: coxedge.InstanceStatusStopped}, reason: InstanceStoppedReason},
+		{name: "failed to provision", instance: &coxedge.InstanceData{Status: coxedge.InstanceStatusFailed}, reason: InstanceFailedReason, failure: capierrors.MachineStatusErrorPtr(capierrors.CreateMachineError)},
+		{name: "crashed", provisioned: true, instance: &coxedge.InstanceData{Status: coxedge.InstanceStatusFailed}, reason: InstanceFailedReason, failure: capierrors.MachineStatusErrorPtr(capierrors.UpdateMachineError)},
+		{name: "missing", provisioned: true, reason: InstanceMissingReason},
+		{name: "being deleted", provisioned: true, instance: &coxedge.InstanceData{Status: coxedge.InstanceStatusDeleting}, reason: InstanceMissingReason},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coxMachine := &coxv1.CoxMachine{}
+			coxMachine.Status.Ready = tt.provisioned
+			machineScope := &scope.MachineScope{Logger: logr.Discard(), CoxMachine: coxMachine}
+
+			if running := reconcileInstanceStatus(machineScope, tt.instance); running != tt.running {
+				t.Errorf("expected running to be %v, got %v", tt.running, running)
+			}
+			if tt.running {
+				if !conditions.IsTrue(coxMachine, InstanceRunningCondition) {
+					t.Error("expected the instance to be reported running")
+				}
+				return
+			}
+			if coxMachine.Status.Ready {
+				t.Error("expected the machine to not be ready")
+			}
+			if reason := conditions.GetReason(coxMachine, InstanceRunningCondition); reason != tt.reason {
+				t.Errorf("expected reason %q, got %q", tt.reason, reason)
+			}
+			if !reflect.DeepEqual(coxMachine.Status.FailureReason, tt.failure) {
+				t.Errorf("unexpected failure reason: %v", coxMachine.Status.FailureReason)
+			}
+		})
+	}
+}
+
+func TestReconcileInstanceStatusLostInstance(t *testing.T) {
+	coxMachine := &coxv1.CoxMachine{}
+	coxMachine.Spec.ProviderID = "coxedge://wl"
+	coxMachine.Status.InstanceID = "i-0"
+	machineScope := &scope.MachineScope{Logger: logr.Discard(), CoxMachine: coxMachine}
+
+	// A gap in the listings of Cox Edge does not fail the machine.
+	if reconcileInstanceStatus(machineScope, nil) || machineScope.HasFailed() {
+		t.Fatal("expected a missing instance to not fail the machine right away")
+	}
+	if !reconcileInstanceStatus(machineScope, &coxedge.InstanceData{Status: coxedge.InstanceStatusRunning}) || machineScope.HasFailed() {
+		t.Fatal("expected the machine to recover when the instance shows up again")
+	}
+
+	if reconcileInstanceStatus(machineScope, nil) || machineScope.HasFailed() {
+		t.Fatal("expected a missing instance to not fail the machine right away")
+	}
+	if reconcileInstanceStatus(machineScope, &coxedge.InstanceData{Status: coxedge.InstanceStatusDeleting}) || machineScope.HasFailed() {
+		t.Fatal("expected an instance being deleted to not fail the machine right away")
+	}
+	coxMachine.Status.Conditions[0].LastTransitionTime.Time = time.Now().Add(-instanceLostGracePeriod)
+	reconcileInstanceStatus(machineScope, nil)
+	if !machineScope.HasFailed() || *coxMachine.Status.FailureReason != capierrors.UpdateMachineError {
+		t.Errorf("expected an instance missing for the grace period to fail the machine, got %v", coxMachine.Status.FailureReason)
+	}
+	if reason := conditions.GetReason(coxMachine, InstanceRunningCondition); reason != InstanceDeletedReason {
+		t.Errorf("expected reason %q, got %q", InstanceDeletedReason, reason)
+	}
+}
+
+func TestSetMachineSummary(t *testing.T) {
+	coxMachine := &coxv1.CoxMachine{}
+	conditions.MarkTrue(coxMachine, BootstrapDataAvailableCondition)
+	conditions.MarkTrue(coxMachine, WorkloadCreatedCondition)
+	conditions.MarkFalse(coxMachine, TaskSucceededCondition, TaskInProgressReason, clusterv1.ConditionSeverityInfo, "Provisioning task t-1 is pending")
+
+	setMachineSummary(coxMachine)
+	ready := conditions.Get(coxMachine, clusterv1.ReadyCondition)
+	if ready == nil || ready.Status != corev1.ConditionFalse || ready.Reason != TaskInProgressReason {
+		t.Fatalf("expected the machine to be stuck at the provisioning task, got %+v", ready)
+	}
+	if ready.Message != "2 of 6 completed" {
+		t.Errorf("unexpected message: %q", ready.Message)
+	}
+
+	for _, step := range []clusterv1.ConditionType{TaskSucceededCondition, InstanceRunningCondition, AddressesAssignedCondition, NodeLinkedCondition} {
+		conditions.MarkTrue(coxMachine, step)
+	}
+	setMachineSummary(coxMachine)
+	if !conditions.IsTrue(coxMachine, clusterv1.ReadyCondition) {
+		t.Errorf("expected the machine to be ready, got %+v", conditions.Get(coxMachine, clusterv1.ReadyCondition))
+	}
+}
+
+func TestReconcileWorkloadAdoptsRequestedWorkload(t *testing.T) {
+	workloads := coxedge.Workloads{}
+	fetched := map[string]coxedge.WorkloadData{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if id := strings.TrimPrefix(r.URL.Path, "/services/svc/env/workloads/"); id != r.URL.Path {
+			workload, ok := fetched[id]
+			if !ok {
+				http.Error(w, "workload not found", http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(coxedge.Workload{Data: workload})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(workloads)
+	}))
+	defer server.Close()
+	coxClient, err := coxedge.NewClient(server.URL, "svc", "env", "key", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newMachineScope := func(requested time.Time) *scope.MachineScope {
+		coxMachine := &coxv1.CoxMachine{ObjectMeta: metav1.ObjectMeta{
+			Name: "test-0",
+			Annotations: map[string]string{
+				coxv1.WorkloadNonceAnnotation:     "nonce",
+				coxv1.WorkloadRequestedAnnotation: requested.UTC().Format(time.RFC3339),
+			},
+		}}
+		return &scope.MachineScope{Logger: logr.Discard(), CoxMachine: coxMachine, CoxClient: coxClient}
+	}
+	r := &CoxMachineReconciler{}
+
+	// A workload that is not listed yet is waited for instead of requested again.
+	if err := r.reconcileWorkload(newMachineScope(time.Now())); err != errWorkloadCreationPending {
+		t.Errorf("expected the workload to be waited for, got %v", err)
+	}
+	// Beyond the grace period, the workload is still not requested again.
+	if err := r.reconcileWorkload(newMachineScope(time.Now().Add(-workloadCreateGracePeriod))); err != errWorkloadRequestUnresolved {
+		t.Errorf("expected the workload request to be unresolved, got %v", err)
+	}
+	machineScope := newMachineScope(time.Now().Add(-workloadCreateGracePeriod))
+	delete(machineScope.CoxMachine.Annotations, coxv1.WorkloadRequestedAnnotation)
+	if err := r.reconcileWorkload(machineScope); err != errWorkloadDeploymentNotFound {
+		t.Errorf("expected the workload to be requested once the request marker is removed, got %v", err)
+	}
+
+	// Workloads listed without their environment variables are fetched.
+	workloads.Data = []coxedge.WorkloadData{{ID: "listed", Name: "test-0"}}
+	fetched["listed"] = coxedge.WorkloadData{ID: "listed", Name: "test-0", EnvironmentVariable: []coxedge.EnvironmentVariable{{Key: coxedge.EnvKeyWorkloadNonce, Value: "nonce"}}}
+	machineScope = newMachineScope(time.Now().Add(-workloadCreateGracePeriod))
+	if err := r.reconcileWorkload(machineScope); err != nil {
+		t.Fatal(err)
+	}
+	if id := machineScope.GetWorkloadID(); id != "listed" {
+		t.Errorf("expected the fetched workload to be adopted, got %q", id)
+	}
+
+	// A workload with the same name but another nonce belongs to another machine.
+	workloads.Data = []coxedge.WorkloadData{
+		{ID: "other", Name: "test-0", EnvironmentVariable: []coxedge.EnvironmentVariable{{Key: coxedge.EnvKeyWorkloadNonce, Value: "other"}}},
+		{ID: "wl", Name: "test-0", EnvironmentVariable: []coxedge.EnvironmentVariable{{Key: coxedge.EnvKeyWorkloadNonce, Value: "nonce"}}},
+	}
+	machineScope = newMachineScope(time.Now())
+	if err := r.reconcileWorkload(machineScope); err != nil {
+		t.Fatal(err)
+	}
+	if id := machineScope.GetWorkloadID(); id != "wl" {
+		t.Errorf("expected the requested workload to be adopted, got %q", id)
+	}
+	if len(machineScope.CoxMachine.Annotations) != 0 {
+		t.Errorf("expected the request markers to be dropped, got %v", machineScope.CoxMachine.Annotations)
+	}
+}
+
+func TestReconcileNormalKeepsRequestAfterServerError(t *testing.T) {
+	var workloads []coxedge.WorkloadData
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/services/svc/env/workloads":
+			// The workload is created, but the response is lost.
+			req := &coxedge.CreateWorkloadRequest{}
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+				t.Error(err)
+			}
+			requests++
+			workloads = append(workloads, coxedge.WorkloadData{ID: "wl", Name: req.Name, EnvironmentVariable: req.EnvironmentVariables})
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		case r.Method == http.MethodGet && r.URL.Path == "/services/svc/env/workloads":
+			_ = json.NewEncoder(w).Encode(coxedge.Workloads{Data: workloads})
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{corev1.AddToScheme, clusterv1.AddToScheme, coxv1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clusterLabels := map[string]string{clusterv1.ClusterLabelName: "cluster"}
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+		Spec:       clusterv1.ClusterSpec{InfrastructureRef: &corev1.ObjectReference{Name: "cluster"}},
+		Status:     clusterv1.ClusterStatus{InfrastructureReady: true},
+	}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-0", Namespace: "default", Labels: clusterLabels},
+		Spec:       clusterv1.MachineSpec{ClusterName: "cluster", Bootstrap: clusterv1.Bootstrap{DataSecretName: pointer.String("bootstrap")}},
+	}
+	coxMachine := &coxv1.CoxMachine{ObjectMeta: metav1.ObjectMeta{
+		Name:      "machine-0",
+		Namespace: "default",
+		UID:       "machine-uid",
+		Labels:    clusterLabels,
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "Machine",
+			Name:       machine.Name,
+		}},
+	}}
+	r := &CoxMachineReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			cluster, machine, coxMachine,
+			&coxv1.CoxCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}},
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "bootstrap", Namespace: "default"}, Data: map[string][]byte{"value": []byte("#cloud-config\n")}},
+		).Build(),
+		Recorder:           record.NewFakeRecorder(100),
+		DefaultCredentials: &scope.Credentials{CoxAPIKey: "key", CoxService: "svc", CoxEnvironment: "env", CoxAPIBaseURL: server.URL},
+		RequeuePolicy:      RequeuePolicy{MinInterval: 5 * time.Second, MaxInterval: time.Hour},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(coxMachine)}
+
+	if _, err := r.Reconcile(context.Background(), req); err == nil {
+		t.Fatal("expected the server error to be returned")
+	}
+	if err := r.Get(context.Background(), req.NamespacedName, coxMachine); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := coxMachine.Annotations[coxv1.WorkloadRequestedAnnotation]; !ok {
+		t.Fatalf("expected the workload request to be kept after a server error, got %v", coxMachine.Annotations)
+	}
+
+	// The workload shows up on the next reconcile and is adopted.
+	_, _ = r.Reconcile(context.Background(), req)
+	if err := r.Get(context.Background(), req.NamespacedName, coxMachine); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("expected the workload to be requested once, got %d requests", requests)
+	}
+	if !strings.HasSuffix(coxMachine.Spec.ProviderID, "wl") {
+		t.Errorf("expected the workload to be adopted, got provider ID %q", coxMachine.Spec.ProviderID)
+	}
+}
+
+func TestReconcileNormalRejectsAmbiguousUserData(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			requests++
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		case r.Method == http.MethodGet && r.URL.Path == "/services/svc/env/workloads":
+			_ = json.NewEncoder(w).Encode(coxedge.Workloads{})
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{corev1.AddToScheme, clusterv1.AddToScheme, coxv1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clusterLabels := map[string]string{clusterv1.ClusterLabelName: "cluster"}
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+		Spec:       clusterv1.ClusterSpec{InfrastructureRef: &corev1.ObjectReference{Name: "cluster"}},
+		Status:     clusterv1.ClusterStatus{InfrastructureReady: true},
+	}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-0", Namespace: "default", Labels: clusterLabels},
+		Spec:       clusterv1.MachineSpec{ClusterName: "cluster", Bootstrap: clusterv1.Bootstrap{DataSecretName: pointer.String("bootstrap")}},
+	}
+	coxMachine := &coxv1.CoxMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine-0",
+			Namespace: "default",
+			Labels:    clusterLabels,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Machine",
+				Name:       machine.Name,
+			}},
+		},
+		Spec: coxv1.CoxMachineSpec{AdditionalUserData: &coxv1.UserDataSource{
+			Value:        "#cloud-config\n",
+			SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "user-data"}, Key: "value"},
+		}},
+	}
+	r := &CoxMachineReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			cluster, machine, coxMachine,
+			&coxv1.CoxCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}},
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "bootstrap", Namespace: "default"}, Data: map[string][]byte{"value": []byte("#cloud-config\n")}},
+		).Build(),
+		Recorder:           record.NewFakeRecorder(100),
+		DefaultCredentials: &scope.Credentials{CoxAPIKey: "key", CoxService: "svc", CoxEnvironment: "env", CoxAPIBaseURL: server.URL},
+		RequeuePolicy:      RequeuePolicy{MinInterval: 5 * time.Second, MaxInterval: time.Hour},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(coxMachine)}
+
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("expected an invalid configuration not to be retried, got %v", err)
+	}
+	if err := r.Get(context.Background(), req.NamespacedName, coxMachine); err != nil {
+		t.Fatal(err)
+	}
+	if coxMachine.Status.FailureReason == nil || *coxMachine.Status.FailureReason != capierrors.InvalidConfigurationMachineError {
+		t.Errorf("expected the machine to fail with an invalid configuration, got %v", coxMachine.Status.FailureReason)
+	}
+	if requests != 0 {
+		t.Errorf("expected no workload to be requested, got %d requests", requests)
+	}
+}
+
+func TestReconcileLoadBalancerDrainOfDeletingCluster(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := coxv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	clusterLabels := map[string]string{clusterv1.ClusterLabelName: "test"}
+	r := &CoxMachineReconciler{
+		Client:     fake.NewClientBuilder().WithScheme(scheme).WithObjects(newTestCoxLoadBalancer("test-control-plane", clusterLabels, nil, "10.0.0.1:6443")).Build(),
+		Recorder:   record.NewFakeRecorder(100),
+		DrainDelay: time.Minute,
+	}
+	now := metav1.Now()
+	machineScope := &scope.MachineScope{
+		Logger:     logr.Discard(),
+		Cluster:    &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", DeletionTimestamp: &now}},
+		CoxCluster: &coxv1.CoxCluster{},
+		Machine:    &clusterv1.Machine{},
+		CoxMachine: newTestCoxMachine("test-0", clusterLabels, "10.0.0.1"),
+	}
+
+	drained, _, err := r.reconcileLoadBalancerDrain(context.Background(), machineScope, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !drained {
+		t.Error("expected the machine of a deleting cluster not to wait for the load balancers of the cluster")
+	}
+}
@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coxv1 "github.com/coxedge/cluster-api-provider-cox/api/v1beta1"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge/scope"
+)
+
+// fakeCoxAPI is an in-memory Cox Edge API serving the workloads and
+// instances used by the controllers.
+type fakeCoxAPI struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	workloads []coxedge.WorkloadData
+	created   int
+	updated   int
+}
+
+func newFakeCoxAPI(workloads ...coxedge.WorkloadData) *fakeCoxAPI {
+	api := &fakeCoxAPI{workloads: workloads}
+	api.Server = httptest.NewServer(http.HandlerFunc(api.serveHTTP))
+	return api
+}
+
+func (a *fakeCoxAPI) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/services/svc/env/")
+	switch {
+	case path == "workloads" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(coxedge.Workloads{Data: a.workloads})
+	case path == "workloads" && r.Method == http.MethodPost:
+		req := &coxedge.CreateWorkloadRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.created++
+		a.workloads = append(a.workloads, coxedge.WorkloadData{
+			ID:                  fmt.Sprintf("wl-created-%d", a.created),
+			Name:                req.Name,
+			Image:               req.Image,
+			Specs:               req.Specs,
+			Deployments:         req.Deployments,
+			EnvironmentVariable: req.EnvironmentVariables,
+		})
+		_ = json.NewEncoder(w).Encode(coxedge.POSTResponse{TaskID: fmt.Sprintf("task-%d", a.created)})
+	case strings.HasPrefix(path, "workloads/"):
+		id := strings.TrimPrefix(path, "workloads/")
+		idx := a.indexOf(id)
+		if idx < 0 {
+			http.Error(w, "workload not found", http.StatusNotFound)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Query().Get("operation") == "delete":
+			a.workloads = append(a.workloads[:idx], a.workloads[idx+1:]...)
+			_ = json.NewEncoder(w).Encode(coxedge.POSTResponse{})
+			return
+		case r.Method == http.MethodPut:
+			a.updated++
+			if err := json.NewDecoder(r.Body).Decode(&a.workloads[idx]); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		_ = json.NewEncoder(w).Encode(coxedge.Workload{Data: a.workloads[idx]})
+	case path == "instances":
+		idx := a.indexOf(r.URL.Query().Get("workloadId"))
+		if idx < 0 {
+			http.Error(w, "workload not found", http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(coxedge.Instances{Data: []coxedge.InstanceData{{
+			Name:            a.workloads[idx].Name + "-0",
+			IPAddress:       []string{"10.0.0.1"},
+			PublicIPAddress: fmt.Sprintf("192.0.2.%d", idx+1),
+			Status:          coxedge.InstanceStatusRunning,
+		}}})
+	default:
+		http.Error(w, "not implemented", http.StatusNotImplemented)
+	}
+}
+
+func (a *fakeCoxAPI) indexOf(id string) int {
+	for i, workload := range a.workloads {
+		if workload.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *fakeCoxAPI) createdWorkloads() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.created
+}
+
+func (a *fakeCoxAPI) updatedWorkloads() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.updated
+}
+
+func (a *fakeCoxAPI) workloadCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.workloads)
+}
+
+// moveObject simulates clusterctl move of a single object: everything but the
+// status and the server generated metadata is created in the target namespace.
+func moveObject(ctx context.Context, obj client.Object, namespace string) {
+	Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj)).To(Succeed())
+	obj.SetNamespace(namespace)
+	obj.SetResourceVersion("")
+	obj.SetUID("")
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetManagedFields(nil)
+	switch o := obj.(type) {
+	case *coxv1.CoxLoadBalancer:
+		o.Status = coxv1.CoxLoadBalancerResourceStatus{}
+	case *coxv1.CoxMachine:
+		o.Status = coxv1.CoxMachineStatus{}
+	}
+	Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+}
+
+var _ = Describe("clusterctl move", func() {
+	const (
+		sourceNamespace = "move-source"
+		targetNamespace = "move-target"
+	)
+	ctx := context.Background()
+
+	It("resumes on the target cluster without creating or adopting other workloads", func() {
+		for _, name := range []string{sourceNamespace, targetNamespace} {
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})).To(Succeed())
+		}
+
+		// Another workload with the name of the machine precedes the one
+		// that is actually backing it.
+		api := newFakeCoxAPI(
+			coxedge.WorkloadData{ID: "wl-other", Name: "machine-0"},
+			coxedge.WorkloadData{ID: "wl-machine", Name: "machine-0"},
+		)
+		defer api.Close()
+
+		credentials := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "cox-credentials", Namespace: sourceNamespace},
+			Data: map[string][]byte{
+				coxedge.CoxAPIKey:      []byte("key"),
+				coxedge.CoxService:     []byte("svc"),
+				coxedge.CoxEnvironment: []byte("env"),
+				coxedge.CoxAPIBaseURL:  []byte(api.URL),
+			},
+		}
+		Expect(k8sClient.Create(ctx, credentials)).To(Succeed())
+
+		coxLoadBalancer := &coxv1.CoxLoadBalancer{
+			ObjectMeta: metav1.ObjectMeta{Name: "lb", Namespace: sourceNamespace},
+			Spec: coxv1.CoxLoadBalancerResourceSpec{
+				Credentials:           &corev1.LocalObjectReference{Name: credentials.Name},
+				Ports:                 []string{"6443"},
+				Backends:              []string{"10.0.0.1:6443"},
+				POP:                   []string{"LAX"},
+				ReplacementStrategy:   coxv1.BlueGreenReplacementStrategy,
+				RetirementGracePeriod: &metav1.Duration{},
+			},
+		}
+		Expect(k8sClient.Create(ctx, coxLoadBalancer)).To(Succeed())
+
+		coxMachine := &coxv1.CoxMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine-0", Namespace: sourceNamespace},
+			Spec:       coxv1.CoxMachineSpec{ProviderID: "coxedge://wl-machine"},
+		}
+		Expect(k8sClient.Create(ctx, coxMachine)).To(Succeed())
+
+		lbReconciler := &CoxLoadBalancerReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(100)}
+		reconcileLoadBalancer := func(namespace string) *coxv1.CoxLoadBalancer {
+			key := client.ObjectKey{Namespace: namespace, Name: coxLoadBalancer.Name}
+			_, err := lbReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			lb := &coxv1.CoxLoadBalancer{}
+			Expect(k8sClient.Get(ctx, key, lb)).To(Succeed())
+			return lb
+		}
+		machineReconciler := &CoxMachineReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(100)}
+		reconcileWorkload := func(namespace string) *coxv1.CoxMachine {
+			m := &coxv1.CoxMachine{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: coxMachine.Name}, m)).To(Succeed())
+			machineScope, err := scope.NewMachineScope(scope.MachineScopeParams{
+				Client:     k8sClient,
+				Cluster:    &clusterv1.Cluster{},
+				Machine:    &clusterv1.Machine{},
+				CoxCluster: &coxv1.CoxCluster{ObjectMeta: metav1.ObjectMeta{Namespace: namespace}, Spec: coxv1.CoxClusterSpec{Credentials: &corev1.LocalObjectReference{Name: credentials.Name}}},
+				CoxMachine: m,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(machineReconciler.reconcileWorkload(machineScope)).To(Succeed())
+			return m
+		}
+
+		By("creating the load balancer and rolling it out to another POP on the source cluster")
+		reconcileLoadBalancer(sourceNamespace)
+		Expect(api.createdWorkloads()).To(Equal(1))
+		lb := reconcileLoadBalancer(sourceNamespace)
+		Expect(lb.Status.Ready).To(BeTrue())
+		lb.Spec.POP = []string{"ORD"}
+		Expect(k8sClient.Update(ctx, lb)).To(Succeed())
+		reconcileLoadBalancer(sourceNamespace)
+		lb = reconcileLoadBalancer(sourceNamespace)
+		Expect(api.createdWorkloads()).To(Equal(2))
+		Expect(lb.Status.Name).NotTo(Equal(lb.Spec.WorkloadName))
+		Expect(lb.Status.Rollout).To(BeNil())
+		activeName := lb.Status.Name
+
+		Expect(reconcileWorkload(sourceNamespace).Spec.ProviderID).To(Equal("coxedge://wl-machine"))
+
+		By("labeling the credentials for move")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(credentials), credentials)).To(Succeed())
+		Expect(credentials.Labels).To(HaveKey(clusterctlv1.ClusterctlMoveLabelName))
+
+		By("moving the objects to the target cluster")
+		moveObject(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: credentials.Name, Namespace: sourceNamespace}}, targetNamespace)
+		moveObject(ctx, &coxv1.CoxLoadBalancer{ObjectMeta: metav1.ObjectMeta{Name: coxLoadBalancer.Name, Namespace: sourceNamespace}}, targetNamespace)
+		moveObject(ctx, &coxv1.CoxMachine{ObjectMeta: metav1.ObjectMeta{Name: coxMachine.Name, Namespace: sourceNamespace}}, targetNamespace)
+
+		By("resuming on the target cluster")
+		lb = reconcileLoadBalancer(targetNamespace)
+		Expect(lb.Status.Name).To(Equal(activeName))
+		Expect(lb.Status.Ready).To(BeTrue())
+		Expect(reconcileWorkload(targetNamespace).Spec.ProviderID).To(Equal("coxedge://wl-machine"))
+		Consistently(api.createdWorkloads, time.Second).Should(Equal(2))
+	})
+
+	It("keeps the load balancers of a cluster owned by it on the target cluster", func() {
+		const namespace = "move-owned"
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+
+		api := newFakeCoxAPI()
+		defer api.Close()
+
+		credentials := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "cox-credentials", Namespace: namespace},
+			Data: map[string][]byte{
+				coxedge.CoxAPIKey:      []byte("key"),
+				coxedge.CoxService:     []byte("svc"),
+				coxedge.CoxEnvironment: []byte("env"),
+				coxedge.CoxAPIBaseURL:  []byte(api.URL),
+			},
+		}
+		Expect(k8sClient.Create(ctx, credentials)).To(Succeed())
+
+		// The CoxCluster owning the load balancer gets a new UID when it is
+		// moved to the target cluster.
+		ownedBy := func(uid string) []metav1.OwnerReference {
+			return []metav1.OwnerReference{{
+				APIVersion: coxv1.GroupVersion.String(),
+				Kind:       "CoxCluster",
+				Name:       "cluster",
+				UID:        types.UID(uid),
+				Controller: pointer.Bool(true),
+			}}
+		}
+		coxLoadBalancer := &coxv1.CoxLoadBalancer{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-lb", Namespace: namespace, OwnerReferences: ownedBy("source-uid")},
+			Spec: coxv1.CoxLoadBalancerResourceSpec{
+				Credentials: &corev1.LocalObjectReference{Name: credentials.Name},
+				Ports:       []string{"6443"},
+				Backends:    []string{"10.0.0.1:6443"},
+				POP:         []string{"LAX"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, coxLoadBalancer)).To(Succeed())
+
+		lbReconciler := &CoxLoadBalancerReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(100)}
+		reconcileLoadBalancer := func() *coxv1.CoxLoadBalancer {
+			_, err := lbReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(coxLoadBalancer)})
+			Expect(err).NotTo(HaveOccurred())
+			lb := &coxv1.CoxLoadBalancer{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(coxLoadBalancer), lb)).To(Succeed())
+			return lb
+		}
+
+		By("creating the load balancer on the source cluster")
+		reconcileLoadBalancer()
+		lb := reconcileLoadBalancer()
+		Expect(lb.Status.Ready).To(BeTrue())
+		Expect(api.createdWorkloads()).To(Equal(1))
+		updated := api.updatedWorkloads()
+
+		By("moving the load balancer and its owner to the target cluster")
+		lb.Finalizers = nil
+		Expect(k8sClient.Update(ctx, lb)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, lb)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(lb), &coxv1.CoxLoadBalancer{}))
+		}).Should(BeTrue())
+		moved := coxLoadBalancer.DeepCopy()
+		moved.ObjectMeta = metav1.ObjectMeta{Name: lb.Name, Namespace: namespace, OwnerReferences: ownedBy("target-uid")}
+		Expect(k8sClient.Create(ctx, moved)).To(Succeed())
+
+		By("resuming on the target cluster without redeploying the load balancer")
+		lb = reconcileLoadBalancer()
+		Expect(lb.Status.Ready).To(BeTrue())
+		Expect(api.createdWorkloads()).To(Equal(1))
+		Expect(api.updatedWorkloads()).To(Equal(updated))
+
+		By("finding the load balancer by its owner when the cluster is deleted")
+		coxClient, err := coxedge.NewClient(api.URL, "svc", "env", "key", "", nil)
+		Expect(err).NotTo(HaveOccurred())
+		deleted, err := coxedge.NewLoadBalancerHelper(coxClient).DeleteOwnedLoadBalancers(ctx, clusterLoadBalancerOwner(namespace, "cluster"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted).To(HaveLen(1))
+		Expect(api.workloadCount()).To(BeZero())
+	})
+})
@@ -0,0 +1,87 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// DefaultRequeuePolicy is the requeue policy used for intervals that are not
+// configured.
+var DefaultRequeuePolicy = RequeuePolicy{
+	MinInterval:   5 * time.Second,
+	MaxInterval:   2 * time.Minute,
+	DriftInterval: 5 * time.Minute,
+}
+
+// RequeuePolicy determines when objects are reconciled again. An object that
+// is waiting on Cox Edge is polled quickly right after it started waiting,
+// backing off exponentially up to a cap the longer it waits. Ready objects
+// are reconciled periodically to correct drift on the Cox Edge side.
+type RequeuePolicy struct {
+	// MinInterval is the requeue interval of an object that just started
+	// waiting.
+	MinInterval time.Duration
+	// MaxInterval caps the requeue interval of an object that keeps waiting.
+	MaxInterval time.Duration
+	// DriftInterval is the requeue interval of ready objects.
+	DriftInterval time.Duration
+}
+
+// Waiting returns the result requeueing an object that has been waiting since
+// the given time. The interval is the time spent waiting so far, so that it
+// doubles with every requeue, bounded by MinInterval and MaxInterval.
+func (p RequeuePolicy) Waiting(since time.Time) ctrl.Result {
+	minInterval, maxInterval := p.MinInterval, p.MaxInterval
+	if minInterval <= 0 {
+		minInterval = DefaultRequeuePolicy.MinInterval
+	}
+	if maxInterval <= 0 {
+		maxInterval = DefaultRequeuePolicy.MaxInterval
+	}
+
+	interval := time.Since(since)
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	if interval < minInterval {
+		interval = minInterval
+	}
+	return ctrl.Result{RequeueAfter: interval}
+}
+
+// WaitingOn returns the result requeueing an object that is waiting for the
+// condition to become true, since the last transition of the condition.
+func (p RequeuePolicy) WaitingOn(getter conditions.Getter, conditionType clusterv1.ConditionType) ctrl.Result {
+	since := time.Now()
+	if condition := conditions.Get(getter, conditionType); condition != nil && !conditions.IsTrue(getter, conditionType) {
+		since = condition.LastTransitionTime.Time
+	}
+	return p.Waiting(since)
+}
+
+// Synced returns the result requeueing a ready object to correct drift.
+func (p RequeuePolicy) Synced() ctrl.Result {
+	if p.DriftInterval <= 0 {
+		return ctrl.Result{RequeueAfter: DefaultRequeuePolicy.DriftInterval}
+	}
+	return ctrl.Result{RequeueAfter: p.DriftInterval}
+}
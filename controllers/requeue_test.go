@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	coxv1 "github.com/coxedge/cluster-api-provider-cox/api/v1beta1"
+)
+
+func TestRequeuePolicy(t *testing.T) {
+	policy := RequeuePolicy{MinInterval: 5 * time.Second, MaxInterval: time.Minute, DriftInterval: 10 * time.Minute}
+
+	if after := policy.Waiting(time.Now()).RequeueAfter; after != 5*time.Second {
+		t.Errorf("expected fast polling right after waiting started, got %s", after)
+	}
+	if after := policy.Waiting(time.Now().Add(-20 * time.Second)).RequeueAfter; after < 20*time.Second || after > 21*time.Second {
+		t.Errorf("expected the interval to back off with the time spent waiting, got %s", after)
+	}
+	if after := policy.Waiting(time.Now().Add(-time.Hour)).RequeueAfter; after != time.Minute {
+		t.Errorf("expected the interval to be capped, got %s", after)
+	}
+	if after := policy.Synced().RequeueAfter; after != 10*time.Minute {
+		t.Errorf("expected the drift interval, got %s", after)
+	}
+	if after := (RequeuePolicy{}).Synced().RequeueAfter; after != DefaultRequeuePolicy.DriftInterval {
+		t.Errorf("expected the default drift interval, got %s", after)
+	}
+
+	coxMachine := &coxv1.CoxMachine{}
+	conditions.MarkFalse(coxMachine, TaskSucceededCondition, TaskInProgressReason, clusterv1.ConditionSeverityInfo, "")
+	coxMachine.Status.Conditions[0].LastTransitionTime.Time = time.Now().Add(-30 * time.Second)
+	if after := policy.WaitingOn(coxMachine, TaskSucceededCondition).RequeueAfter; after < 30*time.Second || after > 31*time.Second {
+		t.Errorf("expected the interval to back off since the condition became false, got %s", after)
+	}
+
+	// An object that is no longer waiting on the condition starts over.
+	conditions.MarkTrue(coxMachine, TaskSucceededCondition)
+	if after := policy.WaitingOn(coxMachine, TaskSucceededCondition).RequeueAfter; after != 5*time.Second {
+		t.Errorf("expected fast polling, got %s", after)
+	}
+}
@@ -23,6 +23,8 @@ import (
 
 	"sigs.k8s.io/cluster-api/controllers/remote"
 
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge/poller"
 	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge/scope"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -49,6 +51,14 @@ var (
 	leaderElectionRenewDeadline time.Duration
 	leaderElectionRetryPeriod   time.Duration
 	syncPeriod                  time.Duration
+	loadBalancerDrainDelay      time.Duration
+	requeuePolicy               controllers.RequeuePolicy
+	pollInterval                time.Duration
+	coxMachineConcurrency       int
+	coxClusterConcurrency       int
+	coxLoadBalancerConcurrency  int
+	apiQPS                      float64
+	apiBurst                    int
 	watchNamespace              = ""
 )
 
@@ -84,9 +94,44 @@ func main() {
 	flag.DurationVar(&syncPeriod, "sync-period", 2*time.Minute,
 		"The minimum interval at which watched resources are reconciled (e.g. 15m)")
 
+	flag.DurationVar(&loadBalancerDrainDelay, "load-balancer-drain-delay", 0,
+		"Time to wait between removing a deleting machine from the load balancer backends and deleting its workload (e.g. 30s)")
+
+	flag.DurationVar(&requeuePolicy.MinInterval, "requeue-min-interval", controllers.DefaultRequeuePolicy.MinInterval,
+		"Interval at which objects that just started waiting on Cox Edge are reconciled again, doubling while they keep waiting (e.g. 5s)")
+
+	flag.DurationVar(&requeuePolicy.MaxInterval, "requeue-max-interval", controllers.DefaultRequeuePolicy.MaxInterval,
+		"Maximum interval at which objects waiting on Cox Edge are reconciled again (e.g. 2m)")
+
+	flag.DurationVar(&requeuePolicy.DriftInterval, "drift-check-interval", controllers.DefaultRequeuePolicy.DriftInterval,
+		"Interval at which ready objects are reconciled to correct drift on the Cox Edge side (e.g. 5m)")
+
+	flag.DurationVar(&pollInterval, "cox-poll-interval", 30*time.Second,
+		"Interval at which the workloads and instances of each Cox Edge environment are listed and shared between machines, 0 to let every machine poll the Cox Edge API (e.g. 30s)")
+
+	flag.IntVar(&coxMachineConcurrency, "coxmachine-concurrency", 10,
+		"Number of CoxMachines to process simultaneously")
+
+	flag.IntVar(&coxClusterConcurrency, "coxcluster-concurrency", 5,
+		"Number of CoxClusters to process simultaneously")
+
+	flag.IntVar(&coxLoadBalancerConcurrency, "coxloadbalancer-concurrency", 5,
+		"Number of CoxLoadBalancers to process simultaneously")
+
+	flag.Float64Var(&apiQPS, "cox-api-qps", 10,
+		"Maximum number of requests per second sent to the Cox Edge API per API key and organization, shared by all controllers, 0 to disable rate limiting")
+
+	flag.IntVar(&apiBurst, "cox-api-burst", 20,
+		"Maximum burst of requests sent to the Cox Edge API per API key and organization")
+
 	flag.StringVar(&watchNamespace, "namespace", "", "namespace")
 	flag.Parse()
 
+	if requeuePolicy.MinInterval > requeuePolicy.MaxInterval {
+		setupLog.Error(nil, "--requeue-min-interval must not be greater than --requeue-max-interval")
+		os.Exit(1)
+	}
+
 	if watchNamespace != "" {
 		setupLog.Info("Watching cluster-api objects only in namespace for reconciliation", "namespace", watchNamespace)
 	}
@@ -131,26 +176,58 @@ func main() {
 		os.Exit(1)
 	}
 
+	clientFactory := coxedge.NewClientFactory(apiQPS, apiBurst)
+
 	if err = (&controllers.CoxClusterReconciler{
-		Client:             mgr.GetClient(),
-		Scheme:             mgr.GetScheme(),
-		Recorder:           mgr.GetEventRecorderFor(controllers.CoxClusterControllerName + "-controller"),
-		DefaultCredentials: defaultCredentials,
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor(controllers.CoxClusterControllerName + "-controller"),
+		DefaultCredentials:      defaultCredentials,
+		RequeuePolicy:           requeuePolicy,
+		ClientFactory:           clientFactory,
+		MaxConcurrentReconciles: coxClusterConcurrency,
 	}).SetupWithManager(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CoxCluster")
 		os.Exit(1)
 	}
 
+	var workloadPoller *poller.Poller
+	if pollInterval > 0 {
+		workloadPoller = poller.New(pollInterval)
+		if err := mgr.Add(workloadPoller); err != nil {
+			setupLog.Error(err, "unable to add the Cox Edge poller")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&controllers.CoxMachineReconciler{
-		Client:             mgr.GetClient(),
-		Scheme:             mgr.GetScheme(),
-		Recorder:           mgr.GetEventRecorderFor(controllers.CoxMachineControllerName + "-controller"),
-		DefaultCredentials: defaultCredentials,
-		Tracker:            tracker,
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor(controllers.CoxMachineControllerName + "-controller"),
+		DefaultCredentials:      defaultCredentials,
+		Tracker:                 tracker,
+		DrainDelay:              loadBalancerDrainDelay,
+		RequeuePolicy:           requeuePolicy,
+		Poller:                  workloadPoller,
+		ClientFactory:           clientFactory,
+		MaxConcurrentReconciles: coxMachineConcurrency,
 	}).SetupWithManager(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CoxMachine")
 		os.Exit(1)
 	}
+
+	if err = (&controllers.CoxLoadBalancerReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor(controllers.CoxLoadBalancerControllerName + "-controller"),
+		DefaultCredentials:      defaultCredentials,
+		RequeuePolicy:           requeuePolicy,
+		ClientFactory:           clientFactory,
+		MaxConcurrentReconciles: coxLoadBalancerConcurrency,
+	}).SetupWithManager(ctx, mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CoxLoadBalancer")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	// if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
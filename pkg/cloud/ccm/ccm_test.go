@@ -0,0 +1,172 @@
+package ccm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+)
+
+// newFakeCoxAPI serves the instances and workloads of a machine workload, and
+// the instance of another workload with the same private IP.
+func newFakeCoxAPI(t *testing.T) *coxedge.Client {
+	t.Helper()
+	instances := []coxedge.InstanceData{
+		{
+			WorkloadID:      "wl-1",
+			Name:            "node-lax-0",
+			IPAddress:       []string{"10.0.0.1"},
+			PublicIPAddress: "192.0.2.1",
+			Status:          coxedge.InstanceStatusRunning,
+			LocationInfo:    coxedge.LocationInfo{CityCode: "lax", CountryCode: "us"},
+		},
+		{
+			WorkloadID:   "wl-2",
+			Name:         "other-lax-0",
+			IPAddress:    []string{"10.0.0.2"},
+			Status:       coxedge.InstanceStatusRunning,
+			LocationInfo: coxedge.LocationInfo{CityCode: "lax", CountryCode: "us"},
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/services/svc/env/instances", func(w http.ResponseWriter, r *http.Request) {
+		workloadID := r.URL.Query().Get("workloadId")
+		var result []coxedge.InstanceData
+		for _, instance := range instances {
+			if len(workloadID) == 0 || instance.WorkloadID == workloadID {
+				result = append(result, instance)
+			}
+		}
+		if len(result) == 0 {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(coxedge.Instances{Data: result})
+	})
+	mux.HandleFunc("/services/svc/env/workloads/wl-1", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(coxedge.Workload{Data: coxedge.WorkloadData{ID: "wl-1", Specs: coxedge.SpecSP2}})
+	})
+	mux.HandleFunc("/services/svc/env/workloads", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(coxedge.Workloads{Data: []coxedge.WorkloadData{{ID: "wl-1"}}})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := coxedge.NewClient(server.URL, "svc", "env", "key", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestInstanceMetadata(t *testing.T) {
+	i := &instances{client: newFakeCoxAPI(t)}
+	ctx := context.Background()
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-lax-0"},
+		Spec:       v1.NodeSpec{ProviderID: "coxedge://wl-1"},
+	}
+	metadata, err := i.InstanceMetadata(ctx, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeExternalIP, Address: "192.0.2.1"},
+		{Type: v1.NodeHostName, Address: "node-lax-0"},
+	}
+	if !reflect.DeepEqual(metadata.NodeAddresses, expected) {
+		t.Errorf("unexpected addresses: %v", metadata.NodeAddresses)
+	}
+	if metadata.Zone != "LAX" || metadata.Region != "US" || metadata.InstanceType != coxedge.SpecSP2 {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+
+	// Nodes that have not been initialized yet are matched by their name.
+	uninitialized := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-lax-0"},
+		Status:     v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.2"}}},
+	}
+	metadata, err = i.InstanceMetadata(ctx, uninitialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.ProviderID != "coxedge://wl-1" {
+		t.Errorf("unexpected provider ID: %s", metadata.ProviderID)
+	}
+
+	// A node is never matched by the internal IP of an instance of another
+	// VPC with overlapping addresses.
+	renamed := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "renamed"},
+		Status:     v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}}},
+	}
+	exists, err := i.InstanceExists(ctx, renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("expected a node not to be matched by its internal IP")
+	}
+
+	exists, err = i.InstanceExists(ctx, &v1.Node{Spec: v1.NodeSpec{ProviderID: "coxedge://wl-deleted"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("expected the instance of a deleted workload to not exist")
+	}
+	shutdown, err := i.InstanceShutdown(ctx, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shutdown {
+		t.Error("expected a running instance to not be shut down")
+	}
+}
+
+func TestLoadBalancerSpec(t *testing.T) {
+	l := &loadBalancers{config: LoadBalancerConfig{Image: defaultLoadBalancerImage, POP: []string{"LAX"}, Specs: coxedge.SpecSP1}}
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ingress",
+			Namespace: "default",
+			UID:       "0a2c7fd6-6c5c-4a4b-9e3a-8b7d3c2f1e0d",
+			Annotations: map[string]string{
+				AnnotationLoadBalancerPOP:     "ord, lax",
+				AnnotationLoadBalancerAnycast: "true",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443}},
+		},
+	}
+	nodes := []*v1.Node{
+		{Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.2"}}}},
+		{Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}}}},
+	}
+
+	spec, err := l.loadBalancerSpec(context.Background(), "cluster", service, nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(spec.Backends, []string{"10.0.0.1:30443", "10.0.0.2:30443"}) {
+		t.Errorf("unexpected backends: %v", spec.Backends)
+	}
+	if !reflect.DeepEqual(spec.POP, []string{"ORD", "LAX"}) || !spec.Anycast || spec.Port[0] != "443" {
+		t.Errorf("unexpected spec: %+v", spec)
+	}
+
+	service.Spec.Ports = append(service.Spec.Ports, v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080})
+	if _, err := l.loadBalancerSpec(context.Background(), "cluster", service, nodes); err == nil {
+		t.Error("expected an error for a service with multiple ports")
+	}
+}
@@ -0,0 +1,147 @@
+package ccm
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	cloudprovider "k8s.io/cloud-provider"
+	"sigs.k8s.io/yaml"
+
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+)
+
+const (
+	// ProviderName is the name of the cloud provider, as passed to --cloud-provider.
+	ProviderName = "coxedge"
+
+	// ProviderIDPrefix is the prefix of the provider IDs of Cox Edge nodes.
+	ProviderIDPrefix = ProviderName + "://"
+)
+
+// Config is the content of the file passed to --cloud-config. Credentials
+// that are not set in the file are read from the environment, using the same
+// variables as the controller manager.
+type Config struct {
+	APIKey       string `json:"apiKey,omitempty"`
+	Service      string `json:"service,omitempty"`
+	Environment  string `json:"environment,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	APIBaseURL   string `json:"apiBaseURL,omitempty"`
+
+	// LoadBalancer contains the defaults for Services of type LoadBalancer.
+	LoadBalancer LoadBalancerConfig `json:"loadBalancer,omitempty"`
+}
+
+// LoadBalancerConfig contains the defaults for Services of type LoadBalancer.
+type LoadBalancerConfig struct {
+	// Image of the load balancer workloads.
+	Image string `json:"image,omitempty"`
+	// POP is the list of POPs the load balancers are deployed to.
+	POP []string `json:"pop,omitempty"`
+	// Specs contains the flavor of the load balancer instances.
+	Specs string `json:"specs,omitempty"`
+}
+
+type cloud struct {
+	client        *coxedge.Client
+	instances     *instances
+	loadBalancers *loadBalancers
+}
+
+var _ cloudprovider.Interface = (*cloud)(nil)
+
+func init() {
+	cloudprovider.RegisterCloudProvider(ProviderName, func(config io.Reader) (cloudprovider.Interface, error) {
+		cfg, err := readConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return newCloud(cfg)
+	})
+}
+
+func readConfig(r io.Reader) (*Config, error) {
+	cfg := &Config{}
+	if r != nil {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read cloud config")
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, errors.Wrap(err, "failed to parse cloud config")
+		}
+	}
+
+	for env, value := range map[string]*string{
+		coxedge.CoxAPIKey:       &cfg.APIKey,
+		coxedge.CoxService:      &cfg.Service,
+		coxedge.CoxEnvironment:  &cfg.Environment,
+		coxedge.CoxOrganization: &cfg.Organization,
+		coxedge.CoxAPIBaseURL:   &cfg.APIBaseURL,
+	} {
+		if len(*value) == 0 {
+			*value = os.Getenv(env)
+		}
+	}
+
+	if len(cfg.APIKey) == 0 || len(cfg.Service) == 0 || len(cfg.Environment) == 0 {
+		return nil, fmt.Errorf("cloud config must contain apiKey, service and environment, or set %s, %s and %s",
+			coxedge.CoxAPIKey, coxedge.CoxService, coxedge.CoxEnvironment)
+	}
+	if len(cfg.LoadBalancer.Image) == 0 {
+		cfg.LoadBalancer.Image = defaultLoadBalancerImage
+	}
+	if len(cfg.LoadBalancer.Specs) == 0 {
+		cfg.LoadBalancer.Specs = coxedge.SpecSP1
+	}
+	return cfg, nil
+}
+
+func newCloud(cfg *Config) (*cloud, error) {
+	client, err := coxedge.NewClient(cfg.APIBaseURL, cfg.Service, cfg.Environment, cfg.APIKey, cfg.Organization, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create coxedge client")
+	}
+	return &cloud{
+		client:        client,
+		instances:     &instances{client: client},
+		loadBalancers: &loadBalancers{client: client, config: cfg.LoadBalancer},
+	}, nil
+}
+
+func (c *cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
+}
+
+func (c *cloud) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
+	return c.loadBalancers, true
+}
+
+func (c *cloud) Instances() (cloudprovider.Instances, bool) {
+	return nil, false
+}
+
+func (c *cloud) InstancesV2() (cloudprovider.InstancesV2, bool) {
+	return c.instances, true
+}
+
+func (c *cloud) Zones() (cloudprovider.Zones, bool) {
+	return nil, false
+}
+
+func (c *cloud) Clusters() (cloudprovider.Clusters, bool) {
+	return nil, false
+}
+
+func (c *cloud) Routes() (cloudprovider.Routes, bool) {
+	return nil, false
+}
+
+func (c *cloud) ProviderName() string {
+	return ProviderName
+}
+
+func (c *cloud) HasClusterID() bool {
+	return true
+}
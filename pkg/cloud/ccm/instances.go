@@ -0,0 +1,126 @@
+package ccm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	cloudprovider "k8s.io/cloud-provider"
+
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+)
+
+// instances implements cloudprovider.InstancesV2. Every node runs in its own
+// Cox Edge workload, which is identified by the provider ID of the node.
+type instances struct {
+	client *coxedge.Client
+}
+
+var _ cloudprovider.InstancesV2 = (*instances)(nil)
+
+func (i *instances) InstanceExists(ctx context.Context, node *v1.Node) (bool, error) {
+	_, _, err := i.instanceForNode(ctx, node)
+	if err == cloudprovider.InstanceNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (i *instances) InstanceShutdown(ctx context.Context, node *v1.Node) (bool, error) {
+	instance, _, err := i.instanceForNode(ctx, node)
+	if err != nil {
+		return false, err
+	}
+	return instance.Status == coxedge.InstanceStatusStopped, nil
+}
+
+func (i *instances) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprovider.InstanceMetadata, error) {
+	instance, workloadID, err := i.instanceForNode(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	workload, err := i.client.GetWorkload(workloadID)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []v1.NodeAddress
+	for _, ip := range instance.IPAddress {
+		addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: ip})
+	}
+	if len(instance.PublicIPAddress) > 0 {
+		addresses = append(addresses, v1.NodeAddress{Type: v1.NodeExternalIP, Address: instance.PublicIPAddress})
+	}
+	addresses = append(addresses, v1.NodeAddress{Type: v1.NodeHostName, Address: node.Name})
+
+	return &cloudprovider.InstanceMetadata{
+		ProviderID:    ProviderIDPrefix + workloadID,
+		InstanceType:  workload.Data.Specs,
+		NodeAddresses: addresses,
+		Zone:          instance.POP(),
+		Region:        strings.ToUpper(instance.LocationInfo.CountryCode),
+	}, nil
+}
+
+// instanceForNode returns the instance backing the node and the ID of its
+// workload. Nodes without a provider ID are matched by the name of their
+// instance. They are never matched by IP, since the private addresses of
+// different VPCs may overlap.
+func (i *instances) instanceForNode(ctx context.Context, node *v1.Node) (*coxedge.InstanceData, string, error) {
+	if len(node.Spec.ProviderID) > 0 {
+		if !strings.HasPrefix(node.Spec.ProviderID, ProviderIDPrefix) {
+			return nil, "", cloudprovider.InstanceNotFound
+		}
+		workloadID := strings.TrimPrefix(node.Spec.ProviderID, ProviderIDPrefix)
+		instance, err := i.findInstance(workloadID, node)
+		return instance, workloadID, err
+	}
+
+	allInstances, err := i.client.GetAllInstances()
+	if err != nil {
+		return nil, "", err
+	}
+	var found *coxedge.InstanceData
+	for idx := range allInstances.Data {
+		instance := &allInstances.Data[idx]
+		if instance.Name != node.Name {
+			continue
+		}
+		if found != nil {
+			return nil, "", fmt.Errorf("node %s matches more than one instance", node.Name)
+		}
+		found = instance
+	}
+	if found == nil {
+		return nil, "", cloudprovider.InstanceNotFound
+	}
+	return found, found.WorkloadID, nil
+}
+
+// findInstance returns the instance of the workload that backs the node.
+func (i *instances) findInstance(workloadID string, node *v1.Node) (*coxedge.InstanceData, error) {
+	workloadInstances, err := i.client.GetInstances(workloadID)
+	if err != nil {
+		if httpErr, ok := err.(*coxedge.HTTPError); ok && httpErr.StatusCode == http.StatusNotFound {
+			return nil, cloudprovider.InstanceNotFound
+		}
+		return nil, err
+	}
+
+	for idx := range workloadInstances.Data {
+		instance := &workloadInstances.Data[idx]
+		if instance.Name == node.Name {
+			return instance, nil
+		}
+	}
+	// Machine workloads run a single instance; the provider ID is authoritative.
+	if len(node.Spec.ProviderID) > 0 && len(workloadInstances.Data) == 1 {
+		return &workloadInstances.Data[0], nil
+	}
+	return nil, cloudprovider.InstanceNotFound
+}
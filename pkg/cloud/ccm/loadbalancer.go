@@ -0,0 +1,185 @@
+package ccm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	cloudprovider "k8s.io/cloud-provider"
+
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+)
+
+const (
+	defaultLoadBalancerImage = "erwinvaneyk/nginx-lb:latest"
+
+	// AnnotationLoadBalancerPOP overrides the POPs of the load balancer, as a
+	// comma separated list.
+	AnnotationLoadBalancerPOP = "service.beta.kubernetes.io/coxedge-load-balancer-pop"
+	// AnnotationLoadBalancerSize sets the number of load balancer instances per POP.
+	AnnotationLoadBalancerSize = "service.beta.kubernetes.io/coxedge-load-balancer-size"
+	// AnnotationLoadBalancerSpecs overrides the flavor of the load balancer instances.
+	AnnotationLoadBalancerSpecs = "service.beta.kubernetes.io/coxedge-load-balancer-specs"
+	// AnnotationLoadBalancerAnycast requests an anycast IP for the load balancer.
+	AnnotationLoadBalancerAnycast = "service.beta.kubernetes.io/coxedge-load-balancer-anycast"
+)
+
+// loadBalancers implements cloudprovider.LoadBalancer with the same nginx
+// workloads that front the control plane of Cox Edge clusters.
+type loadBalancers struct {
+	client *coxedge.Client
+	config LoadBalancerConfig
+}
+
+var _ cloudprovider.LoadBalancer = (*loadBalancers)(nil)
+
+func (l *loadBalancers) GetLoadBalancer(ctx context.Context, clusterName string, service *v1.Service) (*v1.LoadBalancerStatus, bool, error) {
+	lb, err := coxedge.NewLoadBalancerHelper(l.client).GetLoadBalancer(ctx, l.GetLoadBalancerName(ctx, clusterName, service))
+	if err == coxedge.ErrWorkloadNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return loadBalancerStatus(lb), true, nil
+}
+
+func (l *loadBalancers) GetLoadBalancerName(ctx context.Context, clusterName string, service *v1.Service) string {
+	return cloudprovider.DefaultLoadBalancerName(service)
+}
+
+func (l *loadBalancers) EnsureLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	spec, err := l.loadBalancerSpec(ctx, clusterName, service, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	helper := coxedge.NewLoadBalancerHelper(l.client)
+	lb, err := helper.GetLoadBalancer(ctx, spec.Name)
+	if err == coxedge.ErrWorkloadNotFound {
+		if err := helper.CreateLoadBalancer(ctx, spec); err != nil {
+			return nil, err
+		}
+		// The service controller retries with a backoff until the load balancer is running.
+		return nil, fmt.Errorf("load balancer %s has been created and is not running yet", spec.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.updateLoadBalancer(ctx, lb, spec); err != nil {
+		return nil, err
+	}
+	if len(lb.Status.PublicIP) == 0 {
+		return nil, fmt.Errorf("load balancer %s is not running yet", spec.Name)
+	}
+	return loadBalancerStatus(lb), nil
+}
+
+func (l *loadBalancers) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) error {
+	spec, err := l.loadBalancerSpec(ctx, clusterName, service, nodes)
+	if err != nil {
+		return err
+	}
+	lb, err := coxedge.NewLoadBalancerHelper(l.client).GetLoadBalancer(ctx, spec.Name)
+	if err != nil {
+		return err
+	}
+	return l.updateLoadBalancer(ctx, lb, spec)
+}
+
+func (l *loadBalancers) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service) error {
+	return coxedge.NewLoadBalancerHelper(l.client).DeleteLoadBalancer(ctx, l.GetLoadBalancerName(ctx, clusterName, service))
+}
+
+// updateLoadBalancer updates the backends and deployment of the load balancer
+// in place if they differ from the desired spec.
+func (l *loadBalancers) updateLoadBalancer(ctx context.Context, lb *coxedge.LoadBalancer, spec *coxedge.LoadBalancerSpec) error {
+	sort.Strings(lb.Spec.Backends)
+	if reflect.DeepEqual(lb.Spec.Backends, spec.Backends) && lb.Spec.Anycast == spec.Anycast && lb.Spec.DeploymentEqual(spec) {
+		return nil
+	}
+	// Ignore the name of the existing one because it might have been shortened.
+	spec.Name = lb.Spec.Name
+	return coxedge.NewLoadBalancerHelper(l.client).UpdateLoadBalancer(ctx, spec)
+}
+
+// loadBalancerSpec returns the load balancer for the service, routing to the
+// node port of the service on the internal IP of every node.
+func (l *loadBalancers) loadBalancerSpec(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (*coxedge.LoadBalancerSpec, error) {
+	// The nginx load balancer forwards all of its ports to the same set of
+	// backends, so it can only serve a single port of a service.
+	if len(service.Spec.Ports) != 1 {
+		return nil, fmt.Errorf("service %s/%s must have exactly one port to be exposed by a Cox Edge load balancer", service.Namespace, service.Name)
+	}
+	port := service.Spec.Ports[0]
+	if port.Protocol != v1.ProtocolTCP {
+		return nil, fmt.Errorf("protocol %s of service %s/%s is not supported by Cox Edge load balancers", port.Protocol, service.Namespace, service.Name)
+	}
+
+	var backends []string
+	for _, node := range nodes {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != v1.NodeInternalIP {
+				continue
+			}
+			backends = append(backends, net.JoinHostPort(addr.Address, strconv.Itoa(int(port.NodePort))))
+			break
+		}
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("service %s/%s does not have any nodes with an internal IP to route to", service.Namespace, service.Name)
+	}
+	sort.Strings(backends)
+
+	spec := &coxedge.LoadBalancerSpec{
+		Name:      l.GetLoadBalancerName(ctx, clusterName, service),
+		Port:      []string{strconv.Itoa(int(port.Port))},
+		Image:     l.config.Image,
+		Backends:  backends,
+		POP:       l.config.POP,
+		Instances: "1",
+		Specs:     l.config.Specs,
+	}
+	if pops, ok := service.Annotations[AnnotationLoadBalancerPOP]; ok {
+		spec.POP = nil
+		for _, pop := range strings.Split(pops, ",") {
+			if pop = strings.TrimSpace(pop); len(pop) > 0 {
+				spec.POP = append(spec.POP, strings.ToUpper(pop))
+			}
+		}
+	}
+	if len(spec.POP) == 0 {
+		return nil, fmt.Errorf("no POPs configured for the load balancer of service %s/%s, set the %s annotation", service.Namespace, service.Name, AnnotationLoadBalancerPOP)
+	}
+	if size, ok := service.Annotations[AnnotationLoadBalancerSize]; ok {
+		if _, err := strconv.Atoi(size); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation on service %s/%s: %w", AnnotationLoadBalancerSize, service.Namespace, service.Name, err)
+		}
+		spec.Instances = size
+	}
+	if specs, ok := service.Annotations[AnnotationLoadBalancerSpecs]; ok {
+		spec.Specs = specs
+	}
+	if anycast, ok := service.Annotations[AnnotationLoadBalancerAnycast]; ok {
+		enabled, err := strconv.ParseBool(anycast)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation on service %s/%s: %w", AnnotationLoadBalancerAnycast, service.Namespace, service.Name, err)
+		}
+		spec.Anycast = enabled
+	}
+	return spec, nil
+}
+
+func loadBalancerStatus(lb *coxedge.LoadBalancer) *v1.LoadBalancerStatus {
+	status := &v1.LoadBalancerStatus{}
+	for _, addr := range lb.Status.Addresses() {
+		status.Ingress = append(status.Ingress, v1.LoadBalancerIngress{IP: addr})
+	}
+	return status
+}
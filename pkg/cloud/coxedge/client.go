@@ -2,23 +2,34 @@ package coxedge
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
 )
 
 const (
 	baseURLDefault = "https://portal.coxedge.com/api/v1/"
+
+	// EnvKeyWorkloadNonce marks a machine workload with the nonce recorded on
+	// its CoxMachine before the workload was requested, so that the workload
+	// can be adopted if the task ID of its creation was lost.
+	EnvKeyWorkloadNonce = "CAPI_WORKLOAD_NONCE"
 )
 
 var (
 	ErrWorkloadNotFound = errors.New("workload not found")
+	ErrVPCNotFound      = errors.New("vpc not found")
+	ErrSubnetNotFound   = errors.New("subnet not found")
 )
 
 type Client struct {
@@ -28,6 +39,14 @@ type Client struct {
 	service        string
 	environment    string
 	organizationID string
+	limiter        *rate.Limiter
+}
+
+// Key identifies the environment and the credentials the client uses, so
+// that state fetched by one client can be shared with clients of the same key.
+func (c *Client) Key() string {
+	apiKey := sha256.Sum256([]byte(c.apiKey))
+	return strings.Join([]string{c.baseURL.String(), c.service, c.environment, c.organizationID, hex.EncodeToString(apiKey[:])}, "|")
 }
 
 func NewClient(baseURL, service, environment, apiKey string, organizationID string, httpClient *http.Client) (*Client, error) {
@@ -89,6 +108,45 @@ func (c *Client) GetWorkloadByName(name string) (*WorkloadData, error) {
 	return nil, ErrWorkloadNotFound
 }
 
+// GetWorkloadByNonce returns the workload with the name that is marked with
+// the nonce through the EnvKeyWorkloadNonce environment variable. Workloads
+// listed without their environment variables are fetched one by one.
+func (c *Client) GetWorkloadByNonce(nonce, name string) (*WorkloadData, error) {
+	workloads, err := c.GetWorkloads()
+	if err != nil {
+		return nil, err
+	}
+	name = shortenName(name, 18)
+
+	for i := range workloads.Data {
+		workload := &workloads.Data[i]
+		if len(workload.EnvironmentVariable) == 0 && workload.Name == name {
+			w, err := c.GetWorkload(workload.ID)
+			if err != nil {
+				respErr := &HTTPError{}
+				if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+					continue
+				}
+				return nil, err
+			}
+			workload = &w.Data
+		}
+		if hasNonce(workload, nonce) {
+			return workload, nil
+		}
+	}
+	return nil, ErrWorkloadNotFound
+}
+
+func hasNonce(workload *WorkloadData, nonce string) bool {
+	for _, env := range workload.EnvironmentVariable {
+		if env.Key == EnvKeyWorkloadNonce && env.Value == nonce {
+			return true
+		}
+	}
+	return false
+}
+
 // curl -X 'GET' -H 'Mc-Api-Key: $TOKEN' 'https://portal.coxedge.com/api/v1/services/edge-services/faefawef/workloads'
 func (c *Client) GetWorkloads() (*Workloads, error) {
 	w := &Workloads{}
@@ -99,6 +157,18 @@ func (c *Client) GetWorkloads() (*Workloads, error) {
 	return w, nil
 }
 
+// GetAllInstances returns the instances of all workloads of the environment.
+// The listing is not paginated: it is truncated if Metadata.RecordCount
+// exceeds the number of instances returned.
+func (c *Client) GetAllInstances() (*Instances, error) {
+	i := &Instances{}
+	err := c.DoRequest("GET", fmt.Sprintf("/services/%s/%s/instances?%s", c.service, c.environment, c.organizationID), nil, i)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
 // curl -X 'GET' -H 'Mc-Api-Key: $TOKEN' 'https://portal.coxedge.com/api/v1/services/edge-services/faefawef/instances?workloadId=5e1eb085-e9b3-447b-8a0e-c0147fc0ea4d' | jq
 func (c *Client) GetInstances(workloadID string) (*Instances, error) {
 	i := &Instances{}
@@ -184,6 +254,90 @@ func (c *Client) UpdateWorkload(workloadID string, workload WorkloadData) (*POST
 	return pr, err
 }
 
+// curl -X 'GET' -H 'Mc-Api-Key: $TOKEN' 'https://portal.coxedge.com/api/v1/services/edge-services/faefawef/network-policy-rules?workloadId=5e1eb085-e9b3-447b-8a0e-c0147fc0ea4d'
+func (c *Client) GetNetworkPolicyRules(workloadID string) (*NetworkPolicyRules, error) {
+	r := &NetworkPolicyRules{}
+	err := c.DoRequest("GET", fmt.Sprintf("/services/%s/%s/network-policy-rules?workloadId=%s&%s", c.service, c.environment, workloadID, c.organizationID), nil, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (c *Client) CreateNetworkPolicyRule(rule *NetworkPolicyRule) (*POSTResponse, error) {
+	pr := &POSTResponse{}
+	err := c.DoRequest("POST", fmt.Sprintf("/services/%s/%s/network-policy-rules?%s", c.service, c.environment, c.organizationID), rule, pr)
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+func (c *Client) DeleteNetworkPolicyRule(ruleID string) (*POSTResponse, error) {
+	pr := &POSTResponse{}
+	err := c.DoRequest("POST", fmt.Sprintf("/services/%s/%s/network-policy-rules/%s?operation=delete&%s", c.service, c.environment, ruleID, c.organizationID), nil, pr)
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// curl -X 'GET' -H 'Mc-Api-Key: $TOKEN' 'https://portal.coxedge.com/api/v1/services/edge-services/faefawef/vpcs'
+func (c *Client) GetVPCs() (*VPCs, error) {
+	v := &VPCs{}
+	err := c.DoRequest("GET", fmt.Sprintf("/services/%s/%s/vpcs?%s", c.service, c.environment, c.organizationID), nil, v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (c *Client) CreateVPC(vpc *VPC) (*POSTResponse, error) {
+	pr := &POSTResponse{}
+	err := c.DoRequest("POST", fmt.Sprintf("/services/%s/%s/vpcs?%s", c.service, c.environment, c.organizationID), vpc, pr)
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+func (c *Client) DeleteVPC(vpcID string) (*POSTResponse, error) {
+	pr := &POSTResponse{}
+	err := c.DoRequest("POST", fmt.Sprintf("/services/%s/%s/vpcs/%s?operation=delete&%s", c.service, c.environment, vpcID, c.organizationID), nil, pr)
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// curl -X 'GET' -H 'Mc-Api-Key: $TOKEN' 'https://portal.coxedge.com/api/v1/services/edge-services/faefawef/subnets'
+func (c *Client) GetSubnets() (*Subnets, error) {
+	s := &Subnets{}
+	err := c.DoRequest("GET", fmt.Sprintf("/services/%s/%s/subnets?%s", c.service, c.environment, c.organizationID), nil, s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *Client) CreateSubnet(subnet *Subnet) (*POSTResponse, error) {
+	pr := &POSTResponse{}
+	err := c.DoRequest("POST", fmt.Sprintf("/services/%s/%s/subnets?%s", c.service, c.environment, c.organizationID), subnet, pr)
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+func (c *Client) DeleteSubnet(subnetID string) (*POSTResponse, error) {
+	pr := &POSTResponse{}
+	err := c.DoRequest("POST", fmt.Sprintf("/services/%s/%s/subnets/%s?operation=delete&%s", c.service, c.environment, subnetID, c.organizationID), nil, pr)
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
 func (c *Client) DoRequest(method, path string, body, v interface{}) error {
 	req, err := c.NewRequest(method, path, body)
 	if err != nil {
@@ -228,10 +382,20 @@ func (c *Client) NewRequest(method, path string, body interface{}) (*http.Reques
 }
 
 func (c *Client) Do(req *http.Request, v interface{}) error {
+	if c.limiter != nil {
+		start := time.Now()
+		if err := c.limiter.Wait(req.Context()); err != nil {
+			return err
+		}
+		rateLimiterWaitSeconds.Observe(time.Since(start).Seconds())
+	}
+
 	resp, err := c.client.Do(req)
 	if err != nil {
+		apiRequestsTotal.WithLabelValues(req.Method, "error").Inc()
 		return err
 	}
+	apiRequestsTotal.WithLabelValues(req.Method, strconv.Itoa(resp.StatusCode)).Inc()
 
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
@@ -391,6 +555,14 @@ type InstanceData struct {
 	Status                    string       `json:"status"`
 }
 
+// POP returns the code of the PoP the instance is deployed in.
+func (i *InstanceData) POP() string {
+	if len(i.LocationInfo.CityCode) > 0 {
+		return strings.ToUpper(i.LocationInfo.CityCode)
+	}
+	return i.Location
+}
+
 type LocationInfo struct {
 	City            string  `json:"city"`
 	CityCode        string  `json:"cityCode"`
@@ -15,9 +15,17 @@ const (
 
 	PortProtocolTCP = "TCP"
 
+	InstanceStatusScheduling = "SCHEDULING"
+	InstanceStatusStarting   = "STARTING"
+	InstanceStatusRunning    = "RUNNING"
+	InstanceStatusStopping   = "STOPPING"
+	InstanceStatusStopped    = "STOPPED"
+	InstanceStatusFailed     = "FAILED"
+	InstanceStatusDeleting   = "DELETING"
+
 	CoxAPIKey       = "COX_API_KEY"
 	CoxEnvironment  = "COX_ENVIRONMENT"
 	CoxService      = "COX_SERVICE"
 	CoxOrganization = "COX_ORGANIZATION"
-	CoxAPIBaseURL = "COX_APIBASEURL"
+	CoxAPIBaseURL   = "COX_APIBASEURL"
 )
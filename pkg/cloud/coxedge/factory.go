@@ -0,0 +1,66 @@
+package coxedge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// ClientFactory creates clients that share a token-bucket rate limiter per API
+// key and organization, so that the requests of all controllers using the
+// same credentials are smoothed regardless of how many objects they reconcile
+// concurrently.
+type ClientFactory struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewClientFactory returns a factory allowing qps requests per second with
+// bursts of up to burst requests per set of credentials. A qps of 0 or less
+// disables rate limiting.
+func NewClientFactory(qps float64, burst int) *ClientFactory {
+	limit := rate.Limit(qps)
+	if qps <= 0 {
+		limit = rate.Inf
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &ClientFactory{
+		limit:    limit,
+		burst:    burst,
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+// NewClient creates a client sharing the rate limiter of its API key and
+// organization. A nil factory creates clients that are not rate limited.
+func (f *ClientFactory) NewClient(baseURL, service, environment, apiKey, organizationID string) (*Client, error) {
+	client, err := NewClient(baseURL, service, environment, apiKey, organizationID, nil)
+	if err != nil || f == nil {
+		return client, err
+	}
+	client.limiter = f.limiter(apiKey, organizationID)
+	return client, nil
+}
+
+func (f *ClientFactory) limiter(apiKey, organizationID string) *rate.Limiter {
+	// The API key is only kept hashed.
+	sum := sha256.Sum256([]byte(apiKey + "|" + organizationID))
+	key := hex.EncodeToString(sum[:])
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	limiter, ok := f.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(f.limit, f.burst)
+		f.limiters[key] = limiter
+	}
+	return limiter
+}
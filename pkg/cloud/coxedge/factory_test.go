@@ -0,0 +1,51 @@
+package coxedge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientFactoryRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(Workloads{})
+	}))
+	defer server.Close()
+
+	// Requests beyond the burst of 2 are smoothed to 20 per second.
+	factory := NewClientFactory(20, 2)
+	burst := func(clients ...*Client) time.Duration {
+		start := time.Now()
+		for _, client := range clients {
+			if _, err := client.GetWorkloads(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return time.Since(start)
+	}
+	newClient := func(f *ClientFactory, apiKey, organizationID string) *Client {
+		client, err := f.NewClient(server.URL, "svc", "env", apiKey, organizationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	first, second := newClient(factory, "key", "org"), newClient(factory, "key", "org")
+	if elapsed := burst(first, second, first, second, first, second); elapsed < 150*time.Millisecond {
+		t.Errorf("expected clients of the same credentials to share the limit, 6 requests took %s", elapsed)
+	}
+
+	other := newClient(factory, "key", "other-org")
+	if elapsed := burst(other, other); elapsed > 40*time.Millisecond {
+		t.Errorf("expected other credentials not to be limited by the first ones, 2 requests took %s", elapsed)
+	}
+
+	var unlimited *ClientFactory
+	client := newClient(unlimited, "key", "org")
+	if elapsed := burst(client, client, client, client, client, client); elapsed > 40*time.Millisecond {
+		t.Errorf("expected clients of a nil factory not to be limited, 6 requests took %s", elapsed)
+	}
+}
@@ -3,6 +3,10 @@ package coxedge
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -12,6 +16,9 @@ import (
 const (
 	EnvKeyLBBackends = "LB_BACKENDS"
 	EnvKeyLBPort     = "LB_PORT"
+	// EnvKeyLBOwner marks the load balancer workloads of a cluster, so that
+	// they can be found when the cluster is deleted.
+	EnvKeyLBOwner = "LB_OWNER"
 )
 
 type LoadBalancer struct {
@@ -20,16 +27,170 @@ type LoadBalancer struct {
 }
 
 type LoadBalancerSpec struct {
-	Name      string
-	Port      []string
-	Image     string
-	Backends  []string
-	POP       []string
-	Instances string
+	Name     string
+	Port     []string
+	Image    string
+	Backends []string
+	POP      []string
+	// Instances is the number of instances per POP, if AutoScaling is not set.
+	Instances   string
+	AutoScaling *LoadBalancerAutoScaling
+	Specs       string
+	Anycast     bool
+	// Owner identifies the cluster the load balancer belongs to, if any. It
+	// is only set when the workload is created.
+	Owner string
+	// NetworkInterfaces of the load balancer. They are only applied when the
+	// load balancer is created. Defaults to DefaultNetworkInterfaces.
+	NetworkInterfaces []NetworkInterface
+}
+
+// LoadBalancerAutoScaling configures the number of instances per POP based on
+// the CPU utilization of the load balancer.
+type LoadBalancerAutoScaling struct {
+	MinInstancesPerPop string
+	MaxInstancesPerPop string
+	CPUUtilization     int
+}
+
+// DesiredInstances returns the number of instances the load balancer should
+// at least be running across all POPs. A load balancer always needs at least
+// one running instance, also if no POP or number of instances is set.
+func (s *LoadBalancerSpec) DesiredInstances() int {
+	perPop := s.Instances
+	if s.AutoScaling != nil {
+		perPop = s.AutoScaling.MinInstancesPerPop
+	}
+	n, err := strconv.Atoi(perPop)
+	if err != nil || n < 1 {
+		n = 1
+	}
+	pops := len(s.POP)
+	if pops == 0 {
+		pops = 1
+	}
+	return n * pops
+}
+
+// DeploymentEqual returns whether the shape of the deployment (POPs, number
+// of instances and instance spec) of both load balancers is the same. The
+// order of the POPs does not matter.
+func (s *LoadBalancerSpec) DeploymentEqual(other *LoadBalancerSpec) bool {
+	deployment, otherDeployment := s.deployment(), other.deployment()
+	deployment.Pops, otherDeployment.Pops = nil, nil
+	return reflect.DeepEqual(deployment, otherDeployment) && sameElements(s.POP, other.POP) && s.Specs == other.Specs
+}
+
+// RequiresReplacement returns whether moving the load balancer to the desired
+// spec requires a new workload. Changing the image, POPs or instance spec of a
+// workload restarts all of its instances, which would take the load balancer
+// offline.
+func (s *LoadBalancerSpec) RequiresReplacement(desired *LoadBalancerSpec) bool {
+	return s.Image != desired.Image || s.Specs != desired.Specs || !sameElements(s.POP, desired.POP)
+}
+
+// Hash returns a short hash of the properties of the spec that require a
+// replacement of the load balancer when changed.
+func (s *LoadBalancerSpec) Hash() string {
+	pops := append([]string(nil), s.POP...)
+	sort.Strings(pops)
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.Join([]string{s.Image, s.Specs, strings.Join(pops, ",")}, "|")))
+	hash := strconv.FormatUint(uint64(h.Sum32()), 36)
+	if len(hash) > 5 {
+		hash = hash[:5]
+	}
+	return hash
+}
+
+func sameElements(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return reflect.DeepEqual(a, b)
+}
+
+func (s *LoadBalancerSpec) deployment() Deployment {
+	deployment := Deployment{
+		Name: "default",
+		Pops: s.POP,
+	}
+	if s.AutoScaling != nil {
+		deployment.EnableAutoScaling = true
+		deployment.CPUUtilization = s.AutoScaling.CPUUtilization
+		deployment.MinInstancesPerPop = s.AutoScaling.MinInstancesPerPop
+		deployment.MaxInstancesPerPop = s.AutoScaling.MaxInstancesPerPop
+	} else {
+		deployment.InstancesPerPop = s.Instances
+	}
+	return deployment
 }
 
 type LoadBalancerStatus struct {
+	// PublicIP is the stable address of the load balancer. It is the anycast
+	// IP if the load balancer requested one, and the public IP of one of the
+	// running instances otherwise. It is empty until the anycast IP is
+	// assigned, since the address of an instance is not stable.
+	PublicIP   string
+	AnycastIP  string
+	WorkloadID string
+	Instances  []LoadBalancerInstanceStatus
+	// ReadyInstances is the number of instances that are running.
+	ReadyInstances int
+}
+
+// LoadBalancerInstanceStatus describes a single instance of a load balancer.
+type LoadBalancerInstanceStatus struct {
+	Name     string
+	POP      string
 	PublicIP string
+	// PrivateIPs are the addresses the instance connects to backends in the
+	// VPC from.
+	PrivateIPs []string
+	Status     string
+}
+
+// Addresses returns the addresses under which the load balancer can be
+// reached: the anycast IP if there is one, and the public IPs of all running
+// instances otherwise.
+func (s *LoadBalancerStatus) Addresses() []string {
+	if len(s.AnycastIP) > 0 {
+		return []string{s.AnycastIP}
+	}
+	var addresses []string
+	for _, inst := range s.Instances {
+		if inst.Status == InstanceStatusRunning && len(inst.PublicIP) > 0 {
+			addresses = append(addresses, inst.PublicIP)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// environmentVariables returns the configuration of the nginx load balancer
+// serving the given ports.
+func (s *LoadBalancerSpec) environmentVariables(port []string) []EnvironmentVariable {
+	env := []EnvironmentVariable{
+		{
+			Key:   EnvKeyLBPort,
+			Value: strings.Join(port, ","),
+		},
+		{
+			Key:   EnvKeyLBBackends,
+			Value: strings.Join(s.Backends, ";"),
+		},
+	}
+	if len(s.Owner) > 0 {
+		env = append(env, EnvironmentVariable{
+			Key:   EnvKeyLBOwner,
+			Value: s.Owner,
+		})
+	}
+	return env
 }
 
 // LoadBalancerHelper is a manager for creating workload-based load-balancers
@@ -63,41 +224,24 @@ func (l *LoadBalancerHelper) CreateLoadBalancer(ctx context.Context, payload *Lo
 			PublicPort: port,
 		})
 	}
+	specs := payload.Specs
+	if len(specs) == 0 {
+		specs = SpecSP1
+	}
+	networkInterfaces := payload.NetworkInterfaces
+	if len(networkInterfaces) == 0 {
+		networkInterfaces = DefaultNetworkInterfaces()
+	}
 	_, err := l.Client.CreateWorkload(&CreateWorkloadRequest{
-		Name:                payload.Name,
-		Type:                TypeContainer,
-		Image:               payload.Image,
-		AddAnyCastIPAddress: false,
-		Ports:               ports,
-		EnvironmentVariables: []EnvironmentVariable{
-			{
-				Key:   EnvKeyLBPort,
-				Value: strings.Join(payload.Port, ","),
-			},
-			{
-				Key:   EnvKeyLBBackends,
-				Value: strings.Join(payload.Backends, ";"),
-			},
-		},
-		Deployments: []Deployment{
-			{
-				Name:               "default",
-				Pops:               payload.POP,
-				EnableAutoScaling:  true,
-				CPUUtilization:     50,
-				MinInstancesPerPop: "1",
-				MaxInstancesPerPop: "3",
-			},
-		},
-		Specs: SpecSP1,
-		NetworkInterfaces: []NetworkInterface{
-			{
-				VPCSlug:    "default",
-				SubnetSlug: "",
-				IPFamilies: "IPv4",
-				IsPublicIP: true,
-			},
-		},
+		Name:                 payload.Name,
+		Type:                 TypeContainer,
+		Image:                payload.Image,
+		AddAnyCastIPAddress:  payload.Anycast,
+		Ports:                ports,
+		EnvironmentVariables: payload.environmentVariables(payload.Port),
+		Deployments:          []Deployment{payload.deployment()},
+		Specs:                specs,
+		NetworkInterfaces:    networkInterfaces,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create loadBalancer: %w", err)
@@ -108,7 +252,7 @@ func (l *LoadBalancerHelper) CreateLoadBalancer(ctx context.Context, payload *Lo
 func (l *LoadBalancerHelper) UpdateLoadBalancer(ctx context.Context, payload *LoadBalancerSpec) error {
 	workload, err := l.Client.GetWorkloadByName(payload.Name)
 	if err != nil {
-		if !apierrors.IsNotFound(err) {
+		if err != ErrWorkloadNotFound && !apierrors.IsNotFound(err) {
 			return err
 		}
 		return nil
@@ -124,16 +268,15 @@ func (l *LoadBalancerHelper) UpdateLoadBalancer(ctx context.Context, payload *Lo
 	// 	return errors.New("updating the LoadBalancer port is not supported")
 	// }
 
-	workload.EnvironmentVariable = []EnvironmentVariable{
-		{
-			Key:   EnvKeyLBBackends,
-			Value: strings.Join(payload.Backends, ";"),
-		},
-		{
-			Key:   EnvKeyLBPort,
-			Value: strings.Join(existingLoadBalancerSpec.Port, ","),
-		},
+	workload.AddAnyCastIPAddress = payload.Anycast
+	workload.Deployments = []Deployment{payload.deployment()}
+	if len(payload.Specs) > 0 {
+		workload.Specs = payload.Specs
 	}
+	// The owner is only set when a workload is created, since changing the
+	// environment variables restarts the instances.
+	payload.Owner = existingLoadBalancerSpec.Owner
+	workload.EnvironmentVariable = payload.environmentVariables(existingLoadBalancerSpec.Port)
 	_, err = l.Client.UpdateWorkload(workload.ID, *workload)
 	if err != nil {
 		return fmt.Errorf("failed to update loadBalancer: %w", err)
@@ -141,10 +284,31 @@ func (l *LoadBalancerHelper) UpdateLoadBalancer(ctx context.Context, payload *Lo
 	return nil
 }
 
+// DeleteOwnedLoadBalancers deletes every load balancer workload marked with
+// the owner, returning the names of the deleted workloads.
+func (l *LoadBalancerHelper) DeleteOwnedLoadBalancers(ctx context.Context, owner string) ([]string, error) {
+	workloads, err := l.Client.GetWorkloads()
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, workload := range workloads.Data {
+		spec, err := parseLoadBalancerSpecFromWorkload(&workload)
+		if err != nil || spec.Owner != owner {
+			continue
+		}
+		if _, err := l.Client.DeleteWorkload(workload.ID); err != nil {
+			return deleted, fmt.Errorf("failed to delete loadBalancer %s: %w", workload.Name, err)
+		}
+		deleted = append(deleted, workload.Name)
+	}
+	return deleted, nil
+}
+
 func (l *LoadBalancerHelper) DeleteLoadBalancer(ctx context.Context, name string) error {
 	workload, err := l.Client.GetWorkloadByName(name)
 	if err != nil {
-		if !apierrors.IsNotFound(err) {
+		if err != ErrWorkloadNotFound && !apierrors.IsNotFound(err) {
 			return err
 		}
 		return nil
@@ -167,13 +331,6 @@ func parseLoadBalancerFromWorkload(workload *WorkloadData, workloadInstances []I
 	if err != nil {
 		return nil, err
 	}
-	var instanceCount = 0
-	for _, inst := range workloadInstances {
-		if inst.Status == "RUNNING" {
-			instanceCount += 1
-		}
-	}
-	spec.Instances = fmt.Sprint(instanceCount)
 	return &LoadBalancer{
 		Spec:   *spec,
 		Status: *status,
@@ -182,27 +339,52 @@ func parseLoadBalancerFromWorkload(workload *WorkloadData, workloadInstances []I
 
 func parseLoadBalancerStatusFromWorkload(workload *WorkloadData, workloadInstances []InstanceData) (*LoadBalancerStatus, error) {
 	status := &LoadBalancerStatus{}
+	if workload != nil {
+		status.WorkloadID = workload.ID
+	}
 
-	for _, inst := range workloadInstances {
-		if inst.Status == "RUNNING" {
-			if workload != nil {
+	// Sort the instances so that the selected public IP does not flap between
+	// instances when the API returns them in a different order.
+	instances := append([]InstanceData(nil), workloadInstances...)
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].Name < instances[j].Name
+	})
+	for _, inst := range instances {
+		status.Instances = append(status.Instances, LoadBalancerInstanceStatus{
+			Name:       inst.Name,
+			POP:        inst.POP(),
+			PublicIP:   inst.PublicIPAddress,
+			PrivateIPs: inst.IPAddress,
+			Status:     inst.Status,
+		})
+		if inst.Status == InstanceStatusRunning {
+			status.ReadyInstances++
+			if len(status.PublicIP) == 0 {
 				status.PublicIP = inst.PublicIPAddress
 			}
 		}
 	}
 
+	if workload != nil && workload.AddAnyCastIPAddress {
+		status.AnycastIP = workload.AnycastIPAddress
+		status.PublicIP = workload.AnycastIPAddress
+	}
+
 	return status, nil
 }
 
 func parseLoadBalancerSpecFromWorkload(workload *WorkloadData) (*LoadBalancerSpec, error) {
 	var backends []string
 	var port []string
+	var owner string
 	for _, kv := range workload.EnvironmentVariable {
 		switch kv.Key {
 		case EnvKeyLBBackends:
 			backends = strings.Split(kv.Value, ";")
 		case EnvKeyLBPort:
 			port = strings.Split(kv.Value, ",")
+		case EnvKeyLBOwner:
+			owner = kv.Value
 		}
 	}
 
@@ -210,11 +392,27 @@ func parseLoadBalancerSpecFromWorkload(workload *WorkloadData) (*LoadBalancerSpe
 		return nil, errors.New("workload is not a load-balancer")
 	}
 
-	return &LoadBalancerSpec{
+	spec := &LoadBalancerSpec{
 		Name:     workload.Name,
 		Port:     port,
 		Image:    workload.Image,
 		Backends: backends,
-		POP:      workload.Deployments[0].Pops,
-	}, nil
+		Specs:    workload.Specs,
+		Anycast:  workload.AddAnyCastIPAddress,
+		Owner:    owner,
+	}
+	if len(workload.Deployments) > 0 {
+		deployment := workload.Deployments[0]
+		spec.POP = deployment.Pops
+		if deployment.EnableAutoScaling {
+			spec.AutoScaling = &LoadBalancerAutoScaling{
+				MinInstancesPerPop: deployment.MinInstancesPerPop,
+				MaxInstancesPerPop: deployment.MaxInstancesPerPop,
+				CPUUtilization:     deployment.CPUUtilization,
+			}
+		} else {
+			spec.Instances = deployment.InstancesPerPop
+		}
+	}
+	return spec, nil
 }
@@ -0,0 +1,152 @@
+package coxedge
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLoadBalancerStatusFromWorkload(t *testing.T) {
+	instances := []InstanceData{
+		{Name: "lb-test-ord-1", PublicIPAddress: "192.0.2.2", Status: InstanceStatusRunning, LocationInfo: LocationInfo{CityCode: "ord"}},
+		{Name: "lb-test-lax-0", PublicIPAddress: "192.0.2.1", Status: InstanceStatusRunning, LocationInfo: LocationInfo{CityCode: "lax"}},
+		{Name: "lb-test-ord-0", PublicIPAddress: "", Status: "SCHEDULING", LocationInfo: LocationInfo{CityCode: "ord"}},
+	}
+
+	status, err := parseLoadBalancerStatusFromWorkload(&WorkloadData{}, instances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.PublicIP != "192.0.2.1" {
+		t.Errorf("expected the first running instance to be selected, got %q", status.PublicIP)
+	}
+	if got := status.Addresses(); !reflect.DeepEqual(got, []string{"192.0.2.1", "192.0.2.2"}) {
+		t.Errorf("unexpected addresses: %v", got)
+	}
+	if status.Instances[0].POP != "LAX" {
+		t.Errorf("unexpected POP: %q", status.Instances[0].POP)
+	}
+
+	anycast := &WorkloadData{AddAnyCastIPAddress: true, AnycastIPAddress: "198.51.100.1"}
+	status, err = parseLoadBalancerStatusFromWorkload(anycast, instances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.PublicIP != "198.51.100.1" || status.AnycastIP != "198.51.100.1" {
+		t.Errorf("expected the anycast IP to be used, got %q", status.PublicIP)
+	}
+	if got := status.Addresses(); !reflect.DeepEqual(got, []string{"198.51.100.1"}) {
+		t.Errorf("unexpected addresses: %v", got)
+	}
+	if len(status.Instances) != 3 {
+		t.Errorf("expected all instances to be reported, got %d", len(status.Instances))
+	}
+
+	// The address of an instance must not be used while the anycast IP is
+	// not assigned yet, since it would become the control plane endpoint.
+	pending := &WorkloadData{AddAnyCastIPAddress: true}
+	status, err = parseLoadBalancerStatusFromWorkload(pending, instances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.PublicIP) > 0 {
+		t.Errorf("expected no public IP until the anycast IP is assigned, got %q", status.PublicIP)
+	}
+	if status.ReadyInstances != 2 {
+		t.Errorf("expected 2 ready instances, got %d", status.ReadyInstances)
+	}
+}
+
+func TestLoadBalancerSpecDeployment(t *testing.T) {
+	fixed := &LoadBalancerSpec{POP: []string{"LAX", "ORD"}, Instances: "2", Specs: SpecSP1}
+	if n := fixed.DesiredInstances(); n != 4 {
+		t.Errorf("expected 4 desired instances, got %d", n)
+	}
+
+	autoscaled := &LoadBalancerSpec{
+		POP:   []string{"LAX", "ORD"},
+		Specs: SpecSP1,
+		AutoScaling: &LoadBalancerAutoScaling{
+			MinInstancesPerPop: "1",
+			MaxInstancesPerPop: "3",
+			CPUUtilization:     50,
+		},
+	}
+	if n := autoscaled.DesiredInstances(); n != 2 {
+		t.Errorf("expected 2 desired instances, got %d", n)
+	}
+	if fixed.DeploymentEqual(autoscaled) {
+		t.Error("expected fixed and autoscaled deployments to differ")
+	}
+
+	parsed, err := parseLoadBalancerSpecFromWorkload(&WorkloadData{
+		Specs:               SpecSP1,
+		EnvironmentVariable: []EnvironmentVariable{{Key: EnvKeyLBBackends, Value: "192.0.2.1:6443"}},
+		Deployments:         []Deployment{autoscaled.deployment()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.DeploymentEqual(autoscaled) {
+		t.Errorf("expected the parsed deployment to equal the original, got %+v", parsed.deployment())
+	}
+
+	reordered := &LoadBalancerSpec{POP: []string{"ORD", "LAX"}, Instances: "2", Specs: SpecSP1}
+	if !fixed.DeploymentEqual(reordered) {
+		t.Error("expected the order of the POPs not to matter")
+	}
+	noPOP := &LoadBalancerSpec{Instances: "2", Specs: SpecSP1}
+	emptyPOP := &LoadBalancerSpec{POP: []string{}, Instances: "2", Specs: SpecSP1}
+	if !noPOP.DeploymentEqual(emptyPOP) {
+		t.Error("expected no POPs and an empty list of POPs to be equal")
+	}
+	if n := noPOP.DesiredInstances(); n != 2 {
+		t.Errorf("expected 2 desired instances without POPs, got %d", n)
+	}
+	if n := (&LoadBalancerSpec{}).DesiredInstances(); n != 1 {
+		t.Errorf("expected at least 1 desired instance, got %d", n)
+	}
+}
+
+func TestLoadBalancerSpecRequiresReplacement(t *testing.T) {
+	current := &LoadBalancerSpec{Image: "nginx-lb:1", Specs: SpecSP1, POP: []string{"LAX", "ORD"}, Instances: "1"}
+
+	reordered := &LoadBalancerSpec{Image: "nginx-lb:1", Specs: SpecSP1, POP: []string{"ORD", "LAX"}, Instances: "2"}
+	if current.RequiresReplacement(reordered) {
+		t.Error("expected POP order and instance count changes to be applied in place")
+	}
+	if current.Hash() != reordered.Hash() {
+		t.Error("expected the hash to ignore the POP order")
+	}
+
+	for _, desired := range []*LoadBalancerSpec{
+		{Image: "nginx-lb:2", Specs: SpecSP1, POP: []string{"LAX", "ORD"}},
+		{Image: "nginx-lb:1", Specs: "SP-2", POP: []string{"LAX", "ORD"}},
+		{Image: "nginx-lb:1", Specs: SpecSP1, POP: []string{"LAX"}},
+	} {
+		if !current.RequiresReplacement(desired) {
+			t.Errorf("expected %+v to require a replacement", desired)
+		}
+		if current.Hash() == desired.Hash() {
+			t.Errorf("expected %+v to have a different hash", desired)
+		}
+	}
+}
+
+func TestLoadBalancerOwner(t *testing.T) {
+	spec := &LoadBalancerSpec{Name: "lb", Port: []string{"6443"}, Backends: []string{"10.0.0.1:6443"}, Owner: "uid"}
+	parsed, err := parseLoadBalancerSpecFromWorkload(&WorkloadData{Name: "lb", EnvironmentVariable: spec.environmentVariables(spec.Port)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Owner != "uid" || !reflect.DeepEqual(parsed.Backends, spec.Backends) {
+		t.Errorf("unexpected spec: %+v", parsed)
+	}
+
+	// Load balancers without an owner do not get a marker.
+	spec.Owner = ""
+	for _, env := range spec.environmentVariables(spec.Port) {
+		if env.Key == EnvKeyLBOwner {
+			t.Errorf("unexpected owner marker: %+v", env)
+		}
+	}
+}
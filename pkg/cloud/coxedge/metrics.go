@@ -0,0 +1,24 @@
+package coxedge
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	apiRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "capc_cox_api_requests_total",
+		Help: "Number of requests sent to the Cox Edge API, by method and status code.",
+	}, []string{"method", "code"})
+
+	rateLimiterWaitSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "capc_cox_api_rate_limiter_wait_seconds",
+		Help:    "Time requests to the Cox Edge API waited for the client-side rate limiter.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+)
+
+func init() {
+	// Exposed on the metrics endpoint of the controller manager.
+	metrics.Registry.MustRegister(apiRequestsTotal, rateLimiterWaitSeconds)
+}
@@ -0,0 +1,102 @@
+package coxedge
+
+import (
+	"strings"
+)
+
+const (
+	NetworkPolicyRuleTypeInbound = "INBOUND"
+	NetworkPolicyRuleActionAllow = "ALLOW"
+
+	NetworkPolicyProtocolAny = "ANY"
+
+	// AnySource is the source of rules that allow traffic from everywhere.
+	AnySource = "0.0.0.0/0"
+)
+
+type NetworkPolicyRules struct {
+	Data []NetworkPolicyRule `json:"data,omitempty"`
+}
+
+// NetworkPolicyRule is a rule of the network policy of a workload.
+type NetworkPolicyRule struct {
+	ID              string `json:"id,omitempty"`
+	WorkloadID      string `json:"workloadId"`
+	NetworkPolicyID string `json:"networkPolicyId,omitempty"`
+	Description     string `json:"description,omitempty"`
+	Type            string `json:"type"`
+	Action          string `json:"action"`
+	Protocol        string `json:"protocol"`
+	PortRange       string `json:"portRange,omitempty"`
+	Source          string `json:"source"`
+}
+
+// key identifies the traffic matched by the rule, ignoring its description.
+func (r *NetworkPolicyRule) key() string {
+	portRange := r.PortRange
+	if r.Protocol == NetworkPolicyProtocolAny {
+		portRange = ""
+	}
+	return strings.Join([]string{r.Type, r.Action, r.Protocol, portRange, r.Source}, "|")
+}
+
+// ReconcileNetworkPolicyRules makes the inbound rules of the workload match
+// the desired rules: missing rules are created and rules that are not desired,
+// like the rules Cox Edge creates for the ports of a new workload, are
+// deleted. It returns whether any rule has been changed.
+func (c *Client) ReconcileNetworkPolicyRules(workloadID string, desired []NetworkPolicyRule) (bool, error) {
+	existing, err := c.GetNetworkPolicyRules(workloadID)
+	if err != nil {
+		return false, err
+	}
+
+	// Rules are created in the desired order, each only once.
+	var rules []NetworkPolicyRule
+	wanted := map[string]bool{}
+	for _, rule := range desired {
+		rule.WorkloadID = workloadID
+		if len(rule.Type) == 0 {
+			rule.Type = NetworkPolicyRuleTypeInbound
+		}
+		if len(rule.Action) == 0 {
+			rule.Action = NetworkPolicyRuleActionAllow
+		}
+		if !wanted[rule.key()] {
+			wanted[rule.key()] = true
+			rules = append(rules, rule)
+		}
+	}
+
+	// Missing rules are created before stale rules are deleted, so that
+	// replacing a rule does not block the traffic in between.
+	var stale []string
+	present := map[string]bool{}
+	for _, rule := range existing.Data {
+		if rule.WorkloadID != workloadID || rule.Type != NetworkPolicyRuleTypeInbound {
+			continue
+		}
+		if wanted[rule.key()] && !present[rule.key()] {
+			present[rule.key()] = true
+			continue
+		}
+		stale = append(stale, rule.ID)
+	}
+
+	changed := false
+	for i := range rules {
+		if present[rules[i].key()] {
+			continue
+		}
+		if _, err := c.CreateNetworkPolicyRule(&rules[i]); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	for _, id := range stale {
+		if _, err := c.DeleteNetworkPolicyRule(id); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
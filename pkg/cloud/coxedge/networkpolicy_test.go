@@ -0,0 +1,70 @@
+package coxedge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReconcileNetworkPolicyRules(t *testing.T) {
+	rules := []NetworkPolicyRule{
+		// Created by Cox Edge for the ports of the workload.
+		{ID: "default-22", WorkloadID: "wl", Type: NetworkPolicyRuleTypeInbound, Action: NetworkPolicyRuleActionAllow, Protocol: "TCP", PortRange: "22", Source: AnySource},
+		{ID: "default-6443", WorkloadID: "wl", Type: NetworkPolicyRuleTypeInbound, Action: NetworkPolicyRuleActionAllow, Protocol: "TCP", PortRange: "6443", Source: AnySource},
+		{ID: "other", WorkloadID: "other", Type: NetworkPolicyRuleTypeInbound, Action: NetworkPolicyRuleActionAllow, Protocol: "TCP", PortRange: "22", Source: AnySource},
+	}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/services/svc/env/network-policy-rules")
+		switch {
+		case r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(NetworkPolicyRules{Data: rules})
+			return
+		case r.Method == http.MethodPost && r.URL.Query().Get("operation") == "delete":
+			requests = append(requests, "delete "+strings.TrimPrefix(path, "/"))
+		case r.Method == http.MethodPost:
+			rule := NetworkPolicyRule{}
+			if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+				t.Fatal(err)
+			}
+			requests = append(requests, "create "+rule.key())
+		}
+		_ = json.NewEncoder(w).Encode(POSTResponse{})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "svc", "env", "key", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := client.ReconcileNetworkPolicyRules("wl", []NetworkPolicyRule{
+		{Protocol: "TCP", PortRange: "6443", Source: AnySource},
+		{Protocol: "TCP", PortRange: "22", Source: "192.0.2.0/24"},
+		{Protocol: "TCP", PortRange: "22", Source: "192.0.2.0/24"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"create INBOUND|ALLOW|TCP|22|192.0.2.0/24",
+		"delete default-22",
+	}
+	if !changed || !reflect.DeepEqual(requests, expected) {
+		t.Errorf("unexpected requests: %v", requests)
+	}
+
+	requests = nil
+	changed, err = client.ReconcileNetworkPolicyRules("wl", []NetworkPolicyRule{
+		{Protocol: "TCP", PortRange: "22", Source: AnySource},
+		{Protocol: "TCP", PortRange: "6443", Source: AnySource},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || len(requests) != 0 {
+		t.Errorf("expected matching rules to be left alone, got %v", requests)
+	}
+}
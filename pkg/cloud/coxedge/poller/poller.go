@@ -0,0 +1,360 @@
+// Package poller shares the state of Cox Edge workloads and instances between
+// reconcilers, similar to an informer for the Cox Edge API.
+package poller
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+)
+
+// idleIntervals is the number of poll intervals after which an environment
+// that is neither used nor tracked by any object is no longer polled.
+const idleIntervals = 10
+
+// Poller periodically lists the workloads and instances of every Cox Edge
+// environment in use, once per environment and set of credentials, and keeps
+// an indexed view of them. Objects tracking a workload are sent to the
+// Source of the poller when the state of the workload or of its instances
+// changes.
+type Poller struct {
+	interval time.Duration
+	events   chan event.GenericEvent
+
+	mu           sync.Mutex
+	ctx          context.Context
+	environments map[string]*View
+}
+
+// New returns a poller polling every environment at the interval.
+func New(interval time.Duration) *Poller {
+	return &Poller{
+		interval:     interval,
+		events:       make(chan event.GenericEvent),
+		environments: map[string]*View{},
+	}
+}
+
+// Start polls the environments in use until the context is done.
+func (p *Poller) Start(ctx context.Context) error {
+	p.mu.Lock()
+	p.ctx = ctx
+	for _, view := range p.environments {
+		go p.run(ctx, view)
+	}
+	p.mu.Unlock()
+
+	<-ctx.Done()
+	return nil
+}
+
+// Source returns the source of the objects tracking a workload that changed.
+// The objects are sent to a single source, to be watched by one controller.
+func (p *Poller) Source() source.Source {
+	return &source.Channel{Source: p.events}
+}
+
+// View returns the view of the environment of the client, starting to poll
+// the environment if it is not polled yet. It returns nil for a nil poller.
+func (p *Poller) View(coxClient *coxedge.Client) *View {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := coxClient.Key()
+	view, ok := p.environments[key]
+	if !ok {
+		view = newView(coxClient)
+		p.environments[key] = view
+		if p.ctx != nil {
+			go p.run(p.ctx, view)
+		}
+	}
+	view.touch()
+	return view
+}
+
+func (p *Poller) run(ctx context.Context, view *View) {
+	log := ctrl.LoggerFrom(ctx).WithName("poller")
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.poll(ctx, view); err != nil {
+			log.Error(err, "Failed to poll the workloads and instances of Cox Edge")
+		}
+		if p.expire(view) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll refreshes the view and sends the objects tracking a workload that
+// changed since the previous poll.
+func (p *Poller) poll(ctx context.Context, view *View) error {
+	workloads, err := view.client.GetWorkloads()
+	if err != nil {
+		return err
+	}
+	// The workloads are still shared if the instances cannot be listed, or
+	// only partially, but their instances are then looked up through the API.
+	var listed []coxedge.InstanceData
+	complete := false
+	instances, listErr := view.client.GetAllInstances()
+	if listErr == nil {
+		listed = instances.Data
+		complete = instances.Metadata.RecordCount <= len(instances.Data)
+	}
+
+	for _, obj := range view.update(newSnapshot(workloads.Data, listed, complete)) {
+		select {
+		case p.events <- event.GenericEvent{Object: obj}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return listErr
+}
+
+// expire stops polling a view that is idle, returning whether it expired.
+func (p *Poller) expire(view *View) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !view.idle(time.Duration(idleIntervals) * p.interval) {
+		return false
+	}
+	delete(p.environments, view.client.Key())
+	return true
+}
+
+// View is the state of the workloads and instances of a Cox Edge environment
+// as of the last poll. A nil view is empty.
+type View struct {
+	client *coxedge.Client
+
+	mu       sync.RWMutex
+	snapshot *snapshot
+	tracked  map[string]map[types.NamespacedName]client.Object
+	lastUsed time.Time
+}
+
+func newView(coxClient *coxedge.Client) *View {
+	return &View{
+		client:  coxClient,
+		tracked: map[string]map[types.NamespacedName]client.Object{},
+	}
+}
+
+// Workload returns the workload with the ID, if it is in the view. Workloads
+// created since the last poll are not.
+func (v *View) Workload(id string) (*coxedge.WorkloadData, bool) {
+	if v == nil {
+		return nil, false
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.snapshot == nil {
+		return nil, false
+	}
+	workload, ok := v.snapshot.workloads[id]
+	if !ok {
+		return nil, false
+	}
+	return &workload, true
+}
+
+// WorkloadByNonce returns the workload marked with the nonce, if it is in the
+// view.
+func (v *View) WorkloadByNonce(nonce string) (*coxedge.WorkloadData, bool) {
+	if v == nil {
+		return nil, false
+	}
+	v.mu.RLock()
+	id, ok := "", false
+	if v.snapshot != nil {
+		id, ok = v.snapshot.nonces[nonce]
+	}
+	v.mu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+	return v.Workload(id)
+}
+
+// Instances returns the instances of the workload with the ID, if the view
+// has any. A workload without instances in the view may still have some, for
+// example if they were created since the last poll or if the instances could
+// not all be listed, so its instances have to be looked up through the API.
+func (v *View) Instances(workloadID string) ([]coxedge.InstanceData, bool) {
+	if v == nil {
+		return nil, false
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.snapshot == nil || !v.snapshot.instancesComplete {
+		return nil, false
+	}
+	instances := v.snapshot.instances[workloadID]
+	if len(instances) == 0 {
+		return nil, false
+	}
+	return append([]coxedge.InstanceData(nil), instances...), true
+}
+
+// Track sends the object to the Source of the poller whenever the workload
+// with the ID or its instances change. An object tracks a single workload.
+func (v *View) Track(workloadID string, obj client.Object) {
+	if v == nil || len(workloadID) == 0 {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key := client.ObjectKeyFromObject(obj)
+	v.untrack(key)
+	if v.tracked[workloadID] == nil {
+		v.tracked[workloadID] = map[types.NamespacedName]client.Object{}
+	}
+	v.tracked[workloadID][key] = &metav1.PartialObjectMetadata{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+	}
+}
+
+// Untrack stops sending the object to the Source of the poller.
+func (v *View) Untrack(obj client.Object) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.untrack(client.ObjectKeyFromObject(obj))
+}
+
+func (v *View) untrack(key types.NamespacedName) {
+	for workloadID, objects := range v.tracked {
+		delete(objects, key)
+		if len(objects) == 0 {
+			delete(v.tracked, workloadID)
+		}
+	}
+}
+
+func (v *View) touch() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.lastUsed = time.Now()
+}
+
+func (v *View) idle(timeout time.Duration) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return len(v.tracked) == 0 && time.Since(v.lastUsed) > timeout
+}
+
+// update replaces the snapshot of the view, returning the objects tracking a
+// workload that changed. Nothing changed on the first update.
+func (v *View) update(next *snapshot) []client.Object {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	previous := v.snapshot
+	v.snapshot = next
+	if previous == nil {
+		return nil
+	}
+
+	var changed []client.Object
+	notify := func(workloadID string) {
+		for _, obj := range v.tracked[workloadID] {
+			changed = append(changed, obj)
+		}
+	}
+	for id, fingerprint := range next.fingerprints {
+		if previous.fingerprints[id] != fingerprint {
+			notify(id)
+		}
+	}
+	for id := range previous.fingerprints {
+		if _, ok := next.fingerprints[id]; !ok {
+			notify(id)
+		}
+	}
+	return changed
+}
+
+// snapshot indexes the workloads and instances listed by a poll.
+type snapshot struct {
+	workloads    map[string]coxedge.WorkloadData
+	nonces       map[string]string
+	instances    map[string][]coxedge.InstanceData
+	fingerprints map[string]string
+	// instancesComplete is whether all instances of the environment were
+	// listed.
+	instancesComplete bool
+}
+
+func newSnapshot(workloads []coxedge.WorkloadData, instances []coxedge.InstanceData, instancesComplete bool) *snapshot {
+	s := &snapshot{
+		workloads:         map[string]coxedge.WorkloadData{},
+		nonces:            map[string]string{},
+		instances:         map[string][]coxedge.InstanceData{},
+		fingerprints:      map[string]string{},
+		instancesComplete: instancesComplete,
+	}
+	for _, workload := range workloads {
+		s.workloads[workload.ID] = workload
+		for _, env := range workload.EnvironmentVariable {
+			if env.Key == coxedge.EnvKeyWorkloadNonce {
+				s.nonces[env.Value] = workload.ID
+			}
+		}
+	}
+	for _, instance := range instances {
+		s.instances[instance.WorkloadID] = append(s.instances[instance.WorkloadID], instance)
+	}
+	for id, workload := range s.workloads {
+		s.fingerprints[id] = fingerprint(&workload, s.instances[id])
+	}
+	return s
+}
+
+// fingerprint returns the state of a workload and its instances that
+// reconcilers act upon.
+func fingerprint(workload *coxedge.WorkloadData, instances []coxedge.InstanceData) string {
+	states := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		states = append(states, strings.Join([]string{
+			instance.ID,
+			instance.Status,
+			instance.PublicIPAddress,
+			strings.Join(instance.IPAddress, ","),
+		}, "|"))
+	}
+	sort.Strings(states)
+	return workload.Status + "/" + strings.Join(states, "/")
+}
@@ -0,0 +1,174 @@
+package poller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+)
+
+func TestPoll(t *testing.T) {
+	var mu sync.Mutex
+	workloads := []coxedge.WorkloadData{{
+		ID:                  "wl",
+		Status:              "RUNNING",
+		EnvironmentVariable: []coxedge.EnvironmentVariable{{Key: coxedge.EnvKeyWorkloadNonce, Value: "nonce"}},
+	}}
+	instances := []coxedge.InstanceData{{ID: "wl-0", WorkloadID: "wl", Status: coxedge.InstanceStatusStarting}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/workloads"):
+			_ = json.NewEncoder(w).Encode(coxedge.Workloads{Data: workloads})
+		case strings.HasSuffix(r.URL.Path, "/instances"):
+			_ = json.NewEncoder(w).Encode(coxedge.Instances{Data: instances})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	coxClient, err := coxedge.NewClient(server.URL, "svc", "env", "key", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(time.Minute)
+	view := p.View(coxClient)
+	if view != p.View(coxClient) {
+		t.Fatal("expected clients of the same environment to share a view")
+	}
+	if _, ok := view.Workload("wl"); ok {
+		t.Error("expected an empty view before the first poll")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	poll := func() []client.Object {
+		var objects []client.Object
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if err := p.poll(ctx, view); err != nil {
+				t.Error(err)
+			}
+		}()
+		for {
+			select {
+			case e := <-p.events:
+				objects = append(objects, e.Object)
+			case <-done:
+				return objects
+			}
+		}
+	}
+
+	coxMachine := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machine"}}
+	view.Track("wl", coxMachine)
+	if objects := poll(); len(objects) != 0 {
+		t.Errorf("expected no events on the first poll, got %v", objects)
+	}
+	if workload, ok := view.WorkloadByNonce("nonce"); !ok || workload.ID != "wl" {
+		t.Errorf("expected the workload to be found by its nonce, got %v", workload)
+	}
+	if found, ok := view.Instances("wl"); !ok || len(found) != 1 || found[0].ID != "wl-0" {
+		t.Errorf("unexpected instances %v", found)
+	}
+	if _, ok := view.Instances("missing"); ok {
+		t.Error("expected no instances for a workload missing from the view")
+	}
+
+	if objects := poll(); len(objects) != 0 {
+		t.Errorf("expected no events without changes, got %v", objects)
+	}
+
+	mu.Lock()
+	instances[0].Status = coxedge.InstanceStatusRunning
+	instances[0].PublicIPAddress = "192.0.2.1"
+	mu.Unlock()
+	objects := poll()
+	if len(objects) != 1 || client.ObjectKeyFromObject(objects[0]) != client.ObjectKeyFromObject(coxMachine) {
+		t.Errorf("expected the tracking machine to be notified, got %v", objects)
+	}
+
+	view.Untrack(coxMachine)
+	mu.Lock()
+	workloads = nil
+	mu.Unlock()
+	if objects := poll(); len(objects) != 0 {
+		t.Errorf("expected untracked machines not to be notified, got %v", objects)
+	}
+	if _, ok := view.Workload("wl"); ok {
+		t.Error("expected the deleted workload to be removed from the view")
+	}
+
+	var nilPoller *Poller
+	if _, ok := nilPoller.View(coxClient).Workload("wl"); ok {
+		t.Error("expected the view of a nil poller to be empty")
+	}
+}
+
+func TestViewInstancesFallBack(t *testing.T) {
+	workloads := []coxedge.WorkloadData{{ID: "wl"}, {ID: "other"}}
+	instances := []coxedge.InstanceData{{ID: "wl-0", WorkloadID: "wl", Status: coxedge.InstanceStatusRunning}}
+
+	view := newView(nil)
+	view.update(newSnapshot(workloads, instances, true))
+	if found, ok := view.Instances("wl"); !ok || len(found) != 1 {
+		t.Errorf("expected the listed instances, got %v", found)
+	}
+	// The instance listing may omit the instances of a workload, for example
+	// ones created since the last poll, so they must be looked up instead.
+	if found, ok := view.Instances("other"); ok {
+		t.Errorf("expected a workload without listed instances to be looked up through the API, got %v", found)
+	}
+
+	// A truncated or failed instance listing leaves the workloads shared.
+	view.update(newSnapshot(workloads, instances, false))
+	if found, ok := view.Instances("wl"); ok {
+		t.Errorf("expected the instances of an incomplete listing to be looked up through the API, got %v", found)
+	}
+	if _, ok := view.Workload("other"); !ok {
+		t.Error("expected the workloads to remain in the view")
+	}
+}
+
+func TestPollTruncatedInstances(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/workloads"):
+			_ = json.NewEncoder(w).Encode(coxedge.Workloads{Data: []coxedge.WorkloadData{{ID: "wl"}}})
+		case strings.HasSuffix(r.URL.Path, "/instances"):
+			_ = json.NewEncoder(w).Encode(coxedge.Instances{
+				Data:     []coxedge.InstanceData{{ID: "wl-0", WorkloadID: "wl"}},
+				Metadata: coxedge.Metadata{RecordCount: 2},
+			})
+		}
+	}))
+	defer server.Close()
+
+	coxClient, err := coxedge.NewClient(server.URL, "svc", "env", "key", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(time.Minute)
+	view := p.View(coxClient)
+	if err := p.poll(context.Background(), view); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := view.Workload("wl"); !ok {
+		t.Error("expected the workload to be in the view")
+	}
+	if found, ok := view.Instances("wl"); ok {
+		t.Errorf("expected the instances of a truncated listing to be looked up through the API, got %v", found)
+	}
+}
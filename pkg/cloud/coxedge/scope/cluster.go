@@ -36,6 +36,7 @@ type ClusterScopeParams struct {
 	CoxCluster         *coxv1.CoxCluster
 	CoxClient          *coxedge.Client
 	DefaultCredentials *Credentials
+	ClientFactory      *coxedge.ClientFactory
 }
 
 // NewClusterScope creates a new ClusterScope from the supplied parameters.
@@ -68,7 +69,7 @@ func NewClusterScope(params ClusterScopeParams) (*ClusterScope, error) {
 		return nil, errors.New("no default or cluster-specific credentials provided")
 	}
 
-	coxClient, err := coxedge.NewClient(creds.CoxAPIBaseURL, creds.CoxService, creds.CoxEnvironment, creds.CoxAPIKey, creds.CoxOrganization, nil)
+	coxClient, err := params.ClientFactory.NewClient(creds.CoxAPIBaseURL, creds.CoxService, creds.CoxEnvironment, creds.CoxAPIKey, creds.CoxOrganization)
 	if err != nil {
 		return nil, errors.Errorf("error while trying to create instance of coxedge client %s", err.Error())
 	}
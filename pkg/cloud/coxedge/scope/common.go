@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
 )
 
 const (
@@ -24,7 +25,7 @@ type Credentials struct {
 	CoxEnvironment  string
 	CoxService      string
 	CoxOrganization string
-	CoxAPIBaseURL	string
+	CoxAPIBaseURL   string
 }
 
 func (c *Credentials) IsEmpty() bool {
@@ -62,10 +63,28 @@ func GetCredentials(client client.Client, namespace string, name string) (*Crede
 		CoxEnvironment:  string(coxEnvironment),
 		CoxService:      string(coxService),
 		CoxOrganization: string(coxOrganization),
-		CoxAPIBaseURL: string(coxAPIBaseURL),
+		CoxAPIBaseURL:   string(coxAPIBaseURL),
 	}, nil
 }
 
+// EnsureCredentialsMoveLabel labels the referenced credentials secret so that
+// clusterctl move moves it together with the objects using it.
+func EnsureCredentialsMoveLabel(ctx context.Context, c client.Client, namespace string, name string) error {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+		return errors.Wrapf(err, "error getting referenced token secret/%s/%s", namespace, name)
+	}
+	if _, ok := secret.Labels[clusterctlv1.ClusterctlMoveLabelName]; ok {
+		return nil
+	}
+	patch := client.MergeFrom(secret.DeepCopy())
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels[clusterctlv1.ClusterctlMoveLabelName] = ""
+	return c.Patch(ctx, secret, patch)
+}
+
 func ParseFromEnv() (*Credentials, error) {
 	CoxAPIKey, keyExists := os.LookupEnv(EnvCoxAPIKey)
 	if !keyExists {
@@ -0,0 +1,46 @@
+package scope
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/dns"
+)
+
+// DNSProvider returns the DNS provider configured for the CoxCluster, or nil
+// if the cluster does not use DNS for its control plane endpoint.
+func (s *ClusterScope) DNSProvider(ctx context.Context) (dns.Provider, error) {
+	spec := s.CoxCluster.Spec.DNS
+	if spec == nil {
+		return nil, nil
+	}
+
+	switch {
+	case spec.RFC2136 != nil:
+		var secret string
+		if ref := spec.RFC2136.TSIGSecretRef; ref != nil {
+			tsigSecret := &corev1.Secret{}
+			key := types.NamespacedName{Namespace: s.CoxCluster.Namespace, Name: ref.Name}
+			if err := s.client.Get(ctx, key, tsigSecret); err != nil {
+				return nil, errors.Errorf("error getting referenced TSIG secret/%s: %s", key, err)
+			}
+			value, ok := tsigSecret.Data[ref.Key]
+			if !ok {
+				return nil, errors.Errorf("error key %s does not exist in secret/%s", ref.Key, key)
+			}
+			secret = string(value)
+		}
+		return dns.NewRFC2136Provider(dns.RFC2136Config{
+			Server:        spec.RFC2136.Server,
+			Zone:          spec.RFC2136.Zone,
+			TSIGKeyName:   spec.RFC2136.TSIGKeyName,
+			TSIGAlgorithm: spec.RFC2136.TSIGAlgorithm,
+			TSIGSecret:    secret,
+		})
+	default:
+		return nil, errors.New("no DNS provider configured")
+	}
+}
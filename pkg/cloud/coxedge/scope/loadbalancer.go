@@ -0,0 +1,85 @@
+package scope
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coxv1 "github.com/coxedge/cluster-api-provider-cox/api/v1beta1"
+	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
+)
+
+// LoadBalancerScopeParams defines the input parameters used to create a new LoadBalancerScope.
+type LoadBalancerScopeParams struct {
+	Client             client.Client
+	Logger             logr.Logger
+	CoxLoadBalancer    *coxv1.CoxLoadBalancer
+	DefaultCredentials *Credentials
+	ClientFactory      *coxedge.ClientFactory
+}
+
+// NewLoadBalancerScope creates a new LoadBalancerScope from the supplied parameters.
+// This is meant to be called for each reconcile iteration only on CoxLoadBalancerReconciler.
+func NewLoadBalancerScope(params LoadBalancerScopeParams) (*LoadBalancerScope, error) {
+	if params.CoxLoadBalancer == nil {
+		return nil, errors.New("CoxLoadBalancer is required when creating a LoadBalancerScope")
+	}
+
+	helper, err := patch.NewHelper(params.CoxLoadBalancer, params.Client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init patch helper")
+	}
+
+	var creds *Credentials
+	if params.CoxLoadBalancer.Spec.Credentials != nil && len(params.CoxLoadBalancer.Spec.Credentials.Name) > 0 {
+		creds, err = GetCredentials(params.Client, params.CoxLoadBalancer.Namespace, params.CoxLoadBalancer.Spec.Credentials.Name)
+		if err != nil {
+			return nil, err
+		}
+	} else if !params.DefaultCredentials.IsEmpty() {
+		creds = params.DefaultCredentials
+	} else {
+		return nil, errors.New("no default or load balancer-specific credentials provided")
+	}
+
+	coxClient, err := params.ClientFactory.NewClient(creds.CoxAPIBaseURL, creds.CoxService, creds.CoxEnvironment, creds.CoxAPIKey, creds.CoxOrganization)
+	if err != nil {
+		return nil, errors.Errorf("error while trying to create instance of coxedge client %s", err.Error())
+	}
+
+	return &LoadBalancerScope{
+		Logger:          params.Logger,
+		client:          params.Client,
+		CoxLoadBalancer: params.CoxLoadBalancer,
+		CoxClient:       coxClient,
+		patchHelper:     helper,
+	}, nil
+}
+
+// LoadBalancerScope defines the basic context for an actuator to operate upon.
+type LoadBalancerScope struct {
+	logr.Logger
+	client      client.Client
+	patchHelper *patch.Helper
+
+	CoxLoadBalancer *coxv1.CoxLoadBalancer
+	CoxClient       *coxedge.Client
+}
+
+// Close closes the current scope persisting the load balancer configuration and status.
+func (s *LoadBalancerScope) Close() error {
+	return s.patchHelper.Patch(context.TODO(), s.CoxLoadBalancer)
+}
+
+// Name returns the name of the load balancer.
+func (s *LoadBalancerScope) Name() string {
+	return s.CoxLoadBalancer.Name
+}
+
+// Namespace returns the namespace of the load balancer.
+func (s *LoadBalancerScope) Namespace() string {
+	return s.CoxLoadBalancer.Namespace
+}
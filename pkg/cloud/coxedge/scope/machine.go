@@ -19,6 +19,7 @@ package scope
 import (
 	"context"
 	"fmt"
+	"net"
 	"strings"
 
 	"sigs.k8s.io/cluster-api/controllers/remote"
@@ -31,10 +32,13 @@ import (
 	"github.com/coxedge/cluster-api-provider-cox/pkg/cloud/coxedge"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
 
 	clusterv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/api/v1beta1/index"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -49,6 +53,7 @@ type MachineScopeParams struct {
 	CoxMachine         *coxv1.CoxMachine
 	DefaultCredentials *Credentials
 	Tracker            *remote.ClusterCacheTracker
+	ClientFactory      *coxedge.ClientFactory
 }
 
 // NewMachineScope creates a new MachineScope from the supplied parameters.
@@ -89,7 +94,7 @@ func NewMachineScope(params MachineScopeParams) (*MachineScope, error) {
 		return nil, errors.New("no default or cluster-specific credentials provided")
 	}
 
-	coxClient, err := coxedge.NewClient(creds.CoxAPIBaseURL, creds.CoxService, creds.CoxEnvironment, creds.CoxAPIKey, creds.CoxOrganization, nil)
+	coxClient, err := params.ClientFactory.NewClient(creds.CoxAPIBaseURL, creds.CoxService, creds.CoxEnvironment, creds.CoxAPIKey, creds.CoxOrganization)
 	if err != nil {
 		return nil, errors.Errorf("error while trying to create instance of coxedge client %s", err.Error())
 	}
@@ -126,6 +131,20 @@ func (m *MachineScope) Close() error {
 	return m.patchHelper.Patch(context.TODO(), m.CoxMachine)
 }
 
+// PatchObject persists the changes made to the CoxMachine so far, for
+// changes that must not be lost if the reconciliation is interrupted.
+func (m *MachineScope) PatchObject(ctx context.Context) error {
+	if err := m.patchHelper.Patch(ctx, m.CoxMachine); err != nil {
+		return err
+	}
+	helper, err := patch.NewHelper(m.CoxMachine, m.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to init patch helper")
+	}
+	m.patchHelper = helper
+	return nil
+}
+
 // Name returns the CoxMachine name
 func (m *MachineScope) Name() string {
 	return m.CoxMachine.Name
@@ -153,9 +172,19 @@ func (m *MachineScope) GetWorkloadID() string {
 	return strings.Replace(m.GetProviderID(), "coxedge://", "", -1)
 }
 
-// SetErrorMessage sets the CoxMachine status error message.
-func (m *MachineScope) SetErrorMessage(v error) {
-	m.CoxMachine.Status.ErrorMessage = pointer.StringPtr(v.Error())
+// SetFailureReason sets the CoxMachine status failure reason.
+func (m *MachineScope) SetFailureReason(v capierrors.MachineStatusError) {
+	m.CoxMachine.Status.FailureReason = &v
+}
+
+// SetFailureMessage sets the CoxMachine status failure message.
+func (m *MachineScope) SetFailureMessage(v error) {
+	m.CoxMachine.Status.FailureMessage = pointer.StringPtr(v.Error())
+}
+
+// HasFailed returns whether the CoxMachine has a terminal failure.
+func (m *MachineScope) HasFailed() bool {
+	return m.CoxMachine.Status.FailureReason != nil || m.CoxMachine.Status.FailureMessage != nil
 }
 
 // SetAddresses sets the address status.
@@ -183,59 +212,152 @@ func (m *MachineScope) GetRawBootstrapData() (string, error) {
 	return string(value), nil
 }
 
-// SetNodeProviderID patches the node with the ID
-func (m *MachineScope) SetNodeProviderID() error {
-	ctx := context.TODO()
-	remoteClient, err := m.Tracker.GetClient(ctx, util.ObjectKey(m.Cluster))
-	if err != nil {
-		return err
-	}
+// ErrAmbiguousUserDataSource is returned if more than one source of the
+// additional user data of a machine is set.
+var ErrAmbiguousUserDataSource = errors.New("only one of value, secretKeyRef and configMapKeyRef of additionalUserData may be set")
 
-	nodeList := &corev1.NodeList{}
-	if err := remoteClient.List(ctx, nodeList); err != nil {
-		return err
+// GetAdditionalUserData returns the cloud-init user data to merge with the
+// bootstrap data of the machine, or an empty string if there is none.
+func (m *MachineScope) GetAdditionalUserData() (string, error) {
+	source := m.CoxMachine.Spec.AdditionalUserData
+	if source == nil {
+		return "", nil
 	}
 
-	mamap := make(map[string]bool)
-
-	for _, address := range m.CoxMachine.Status.Addresses {
-		if address.Type == corev1.NodeInternalIP {
-			mamap[address.Address] = true
+	switch {
+	case len(source.Value) > 0 && (source.SecretKeyRef != nil || source.ConfigMapKeyRef != nil),
+		source.SecretKeyRef != nil && source.ConfigMapKeyRef != nil:
+		return "", ErrAmbiguousUserDataSource
+
+	case source.SecretKeyRef != nil:
+		ref := source.SecretKeyRef
+		secret := &corev1.Secret{}
+		key := types.NamespacedName{Namespace: m.Namespace(), Name: ref.Name}
+		if err := m.client.Get(context.TODO(), key, secret); err != nil {
+			if apierrors.IsNotFound(err) && pointer.BoolDeref(ref.Optional, false) {
+				return "", nil
+			}
+			return "", errors.Wrapf(err, "failed to retrieve additional user data secret %s/%s", m.Namespace(), ref.Name)
 		}
-	}
-
-	node := &corev1.Node{}
-	nodeFound := false
-	for _, n := range nodeList.Items {
-		for _, address := range n.Status.Addresses {
-			if address.Type == corev1.NodeInternalIP {
-				if mamap[address.Address] {
-					nodeFound = true
-					node = n.DeepCopy()
-					break
-				}
+		value, ok := secret.Data[ref.Key]
+		if !ok && !pointer.BoolDeref(ref.Optional, false) {
+			return "", errors.Errorf("additional user data secret %s/%s has no key %s", m.Namespace(), ref.Name, ref.Key)
+		}
+		return string(value), nil
+
+	case source.ConfigMapKeyRef != nil:
+		ref := source.ConfigMapKeyRef
+		configMap := &corev1.ConfigMap{}
+		key := types.NamespacedName{Namespace: m.Namespace(), Name: ref.Name}
+		if err := m.client.Get(context.TODO(), key, configMap); err != nil {
+			if apierrors.IsNotFound(err) && pointer.BoolDeref(ref.Optional, false) {
+				return "", nil
 			}
+			return "", errors.Wrapf(err, "failed to retrieve additional user data config map %s/%s", m.Namespace(), ref.Name)
 		}
-		if nodeFound {
-			break
+		value, ok := configMap.Data[ref.Key]
+		if !ok && !pointer.BoolDeref(ref.Optional, false) {
+			return "", errors.Errorf("additional user data config map %s/%s has no key %s", m.Namespace(), ref.Name, ref.Key)
 		}
+		return value, nil
 	}
 
-	if !nodeFound {
-		return nil
+	return source.Value, nil
+}
+
+// normalizeIP returns the canonical form of an IP address, so that IPv6
+// addresses written in different notations match.
+func normalizeIP(address string) string {
+	if ip := net.ParseIP(address); ip != nil {
+		return ip.String()
 	}
+	return address
+}
 
+// SetNodeProviderID links the Node of the machine in the workload cluster by
+// setting its provider ID. The Node is looked up by provider ID, by the name of
+// the instance, and finally among the unlinked Nodes by system UUID or any of
+// the addresses of the machine. It returns whether the Node has been found.
+func (m *MachineScope) SetNodeProviderID(ctx context.Context) (bool, error) {
+	remoteClient, err := m.Tracker.GetClient(ctx, util.ObjectKey(m.Cluster))
+	if err != nil {
+		return false, err
+	}
 	providerID := m.GetProviderID()
-	if node.Spec.ProviderID == providerID {
-		return nil
+
+	// Nodes are indexed by provider ID in the cache of the workload cluster.
+	nodeList := &corev1.NodeList{}
+	if err := remoteClient.List(ctx, nodeList, client.MatchingFields{index.NodeProviderIDField: providerID}); err != nil {
+		return false, err
+	}
+	for _, node := range nodeList.Items {
+		if node.Spec.ProviderID == providerID {
+			return true, nil
+		}
+	}
+
+	node, err := m.findNode(ctx, remoteClient)
+	if err != nil || node == nil {
+		return false, err
 	}
 
 	patchHelper, err := patch.NewHelper(node, remoteClient)
 	if err != nil {
-		return err
+		return false, err
 	}
-
 	node.Spec.ProviderID = providerID
+	if err := patchHelper.Patch(ctx, node); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// findNode returns the unlinked Node of the machine, or nil if there is none.
+func (m *MachineScope) findNode(ctx context.Context, remoteClient client.Client) (*corev1.Node, error) {
+	names := []string{}
+	addresses := map[string]bool{}
+	for _, address := range m.CoxMachine.Status.Addresses {
+		switch address.Type {
+		case corev1.NodeHostName, corev1.NodeInternalDNS, corev1.NodeExternalDNS:
+			names = append(names, address.Address)
+		default:
+			addresses[normalizeIP(address.Address)] = true
+		}
+	}
+	names = append(names, m.Name())
+	for _, name := range names {
+		node := &corev1.Node{}
+		err := remoteClient.Get(ctx, client.ObjectKey{Name: name}, node)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if node.Spec.ProviderID == "" {
+			return node, nil
+		}
+	}
 
-	return patchHelper.Patch(ctx, node)
+	nodeList := &corev1.NodeList{}
+	if err := remoteClient.List(ctx, nodeList); err != nil {
+		return nil, err
+	}
+	instanceID := m.CoxMachine.Status.InstanceID
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		// Nodes that are linked to another machine are never taken over.
+		if node.Spec.ProviderID != "" {
+			continue
+		}
+		if instanceID != "" && strings.EqualFold(node.Status.NodeInfo.SystemUUID, instanceID) {
+			return node, nil
+		}
+		for _, address := range node.Status.Addresses {
+			if addresses[normalizeIP(address.Address)] {
+				return node, nil
+			}
+		}
+	}
+	return nil, nil
 }
@@ -0,0 +1,177 @@
+package scope
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	coxv1 "github.com/coxedge/cluster-api-provider-cox/api/v1beta1"
+)
+
+func newTestNode(name, providerID, systemUUID string, addresses ...corev1.NodeAddress) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{ProviderID: providerID},
+		Status: corev1.NodeStatus{
+			Addresses: addresses,
+			NodeInfo:  corev1.NodeSystemInfo{SystemUUID: systemUUID},
+		},
+	}
+}
+
+func TestSetNodeProviderID(t *testing.T) {
+	cluster := &clusterv1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	newMachineScope := func(nodes ...client.Object) (*MachineScope, client.Client) {
+		scheme := runtime.NewScheme()
+		if err := corev1.AddToScheme(scheme); err != nil {
+			t.Fatal(err)
+		}
+		remoteClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(nodes...).Build()
+		return &MachineScope{
+			Cluster: cluster,
+			CoxMachine: &coxv1.CoxMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine-0", Namespace: "default"},
+				Spec:       coxv1.CoxMachineSpec{ProviderID: "coxedge://wl"},
+				Status: coxv1.CoxMachineStatus{
+					InstanceID: "5E1EB085-E9B3-447B-8A0E-C0147FC0EA4D",
+					Addresses: []corev1.NodeAddress{
+						{Type: corev1.NodeExternalIP, Address: "192.0.2.1"},
+						{Type: corev1.NodeInternalIP, Address: "2001:db8::1"},
+						{Type: corev1.NodeHostName, Address: "instance-0"},
+					},
+				},
+			},
+			Tracker: remote.NewTestClusterCacheTracker(logr.Discard(), remoteClient, scheme, client.ObjectKeyFromObject(cluster)),
+		}, remoteClient
+	}
+	expectLinked := func(t *testing.T, machineScope *MachineScope, remoteClient client.Client, name string) {
+		t.Helper()
+		linked, err := machineScope.SetNodeProviderID(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !linked {
+			t.Fatalf("expected node %s to be linked", name)
+		}
+		node := &corev1.Node{}
+		if err := remoteClient.Get(context.Background(), client.ObjectKey{Name: name}, node); err != nil {
+			t.Fatal(err)
+		}
+		if node.Spec.ProviderID != "coxedge://wl" {
+			t.Errorf("expected node %s to have the provider ID of the machine, got %q", name, node.Spec.ProviderID)
+		}
+	}
+
+	t.Run("by hostname", func(t *testing.T) {
+		machineScope, remoteClient := newMachineScope(newTestNode("instance-0", "", ""))
+		expectLinked(t, machineScope, remoteClient, "instance-0")
+	})
+
+	t.Run("by system UUID", func(t *testing.T) {
+		machineScope, remoteClient := newMachineScope(
+			newTestNode("other", "", "00000000-0000-0000-0000-000000000000"),
+			newTestNode("renamed", "", "5e1eb085-e9b3-447b-8a0e-c0147fc0ea4d"),
+		)
+		expectLinked(t, machineScope, remoteClient, "renamed")
+	})
+
+	t.Run("by any address", func(t *testing.T) {
+		machineScope, remoteClient := newMachineScope(
+			newTestNode("renamed", "", "", corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "2001:DB8:0::1"}),
+		)
+		expectLinked(t, machineScope, remoteClient, "renamed")
+	})
+
+	t.Run("already linked", func(t *testing.T) {
+		machineScope, remoteClient := newMachineScope(newTestNode("linked", "coxedge://wl", ""))
+		expectLinked(t, machineScope, remoteClient, "linked")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		// Nodes of other machines are never taken over, even if they match.
+		machineScope, remoteClient := newMachineScope(
+			newTestNode("instance-0", "coxedge://other", ""),
+			newTestNode("unrelated", "", "", corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}),
+		)
+		linked, err := machineScope.SetNodeProviderID(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if linked {
+			t.Error("expected no node to be linked")
+		}
+		node := &corev1.Node{}
+		if err := remoteClient.Get(context.Background(), client.ObjectKey{Name: "instance-0"}, node); err != nil {
+			t.Fatal(err)
+		}
+		if node.Spec.ProviderID != "coxedge://other" {
+			t.Errorf("expected the node of another machine to be left alone, got %q", node.Spec.ProviderID)
+		}
+	})
+}
+
+func TestGetAdditionalUserData(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "user-data", Namespace: "default"},
+			Data:       map[string][]byte{"value": []byte("#cloud-config\npackages: [nfs-common]\n")},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "user-data", Namespace: "default"},
+			Data:       map[string]string{"sysctl.sh": "#!/bin/sh\nsysctl -w vm.max_map_count=262144\n"},
+		},
+	).Build()
+	getUserData := func(source *coxv1.UserDataSource) (string, error) {
+		machineScope := &MachineScope{
+			client: c,
+			CoxMachine: &coxv1.CoxMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine-0", Namespace: "default"},
+				Spec:       coxv1.CoxMachineSpec{AdditionalUserData: source},
+			},
+		}
+		return machineScope.GetAdditionalUserData()
+	}
+	ref := corev1.LocalObjectReference{Name: "user-data"}
+	missing := corev1.LocalObjectReference{Name: "missing"}
+	optional := true
+
+	for name, tc := range map[string]struct {
+		source   *coxv1.UserDataSource
+		expected string
+		err      bool
+	}{
+		"none":                {},
+		"inline":              {source: &coxv1.UserDataSource{Value: "#cloud-config\n"}, expected: "#cloud-config\n"},
+		"secret":              {source: &coxv1.UserDataSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: ref, Key: "value"}}, expected: "#cloud-config\npackages: [nfs-common]\n"},
+		"config map":          {source: &coxv1.UserDataSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: ref, Key: "sysctl.sh"}}, expected: "#!/bin/sh\nsysctl -w vm.max_map_count=262144\n"},
+		"missing key":         {source: &coxv1.UserDataSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: ref, Key: "other"}}, err: true},
+		"missing secret":      {source: &coxv1.UserDataSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: missing, Key: "value"}}, err: true},
+		"optional config map": {source: &coxv1.UserDataSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: missing, Key: "value", Optional: &optional}}},
+		"several sources":     {source: &coxv1.UserDataSource{Value: "#cloud-config\n", ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: ref, Key: "sysctl.sh"}}, err: true},
+	} {
+		userData, err := getUserData(tc.source)
+		if (err != nil) != tc.err || userData != tc.expected {
+			t.Errorf("%s: unexpected user data %q, error %v", name, userData, err)
+		}
+	}
+
+	_, err := getUserData(&coxv1.UserDataSource{
+		SecretKeyRef:    &corev1.SecretKeySelector{LocalObjectReference: ref, Key: "value"},
+		ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: ref, Key: "sysctl.sh"},
+	})
+	if err != ErrAmbiguousUserDataSource {
+		t.Errorf("expected several sources to be rejected, got %v", err)
+	}
+}
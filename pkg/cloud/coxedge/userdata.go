@@ -0,0 +1,169 @@
+package coxedge
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MaxUserDataSize is the maximum size in bytes of the user data of a workload
+// accepted by Cox Edge.
+const MaxUserDataSize = 64 * 1024
+
+var (
+	ErrUserDataTooLarge        = errors.New("user data too large")
+	ErrUnsupportedUserData     = errors.New("unsupported user data format")
+	ErrNestedMultipartUserData = errors.New("nested multipart user data is not supported")
+)
+
+// additionalUserDataMergeType makes cloud-init append the lists of additional
+// cloud-config to those of the bootstrap data, such as runcmd or write_files,
+// instead of replacing them, and keep the other keys of the bootstrap data.
+const additionalUserDataMergeType = "list(append)+dict(no_replace,recurse_list)+str()"
+
+// userDataTypes maps the first line of cloud-init user data to its MIME type,
+// longest prefix first.
+var userDataTypes = []struct {
+	prefix      string
+	contentType string
+}{
+	{"## template: jinja", "text/jinja2"},
+	{"#cloud-config-archive", "text/cloud-config-archive"},
+	{"#cloud-config", "text/cloud-config"},
+	{"#cloud-boothook", "text/cloud-boothook"},
+	{"#include-once", "text/x-include-once-url"},
+	{"#include", "text/x-include-url"},
+	{"#part-handler", "text/part-handler"},
+	{"#upstart-job", "text/upstart-job"},
+	{"#!", "text/x-shellscript"},
+}
+
+type userDataPart struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// MergeUserData merges additional cloud-init user data with the bootstrap
+// data of a machine into a MIME multipart archive, bootstrap data first.
+// Multipart user data is flattened into the archive. Without additional user
+// data, the bootstrap data is returned as is. The result must not exceed
+// MaxUserDataSize.
+func MergeUserData(bootstrapData string, additional ...string) (string, error) {
+	userData := bootstrapData
+	if len(additional) > 0 {
+		parts, err := userDataParts(bootstrapData)
+		if err != nil {
+			return "", errors.Wrap(err, "invalid bootstrap data")
+		}
+		for _, data := range additional {
+			additionalParts, err := userDataParts(data)
+			if err != nil {
+				return "", errors.Wrap(err, "invalid additional user data")
+			}
+			for _, part := range additionalParts {
+				if part.header.Get("Merge-Type") == "" && part.header.Get("X-Merge-Type") == "" {
+					part.header.Set("Merge-Type", additionalUserDataMergeType)
+				}
+			}
+			parts = append(parts, additionalParts...)
+		}
+
+		userData, err = writeMultipart(parts)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(userData) > MaxUserDataSize {
+		return "", fmt.Errorf("%w: %d bytes exceed the limit of %d bytes", ErrUserDataTooLarge, len(userData), MaxUserDataSize)
+	}
+	return userData, nil
+}
+
+// userDataParts returns the parts of multipart user data, or single user data
+// as a part of the MIME type of its first line.
+func userDataParts(data string) ([]userDataPart, error) {
+	if !isMultipart(data) {
+		for _, t := range userDataTypes {
+			if strings.HasPrefix(data, t.prefix) {
+				header := textproto.MIMEHeader{}
+				header.Set("Content-Type", t.contentType+`; charset="utf-8"`)
+				return []userDataPart{{header: header, body: []byte(data)}}, nil
+			}
+		}
+		firstLine := strings.SplitN(data, "\n", 2)[0]
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedUserData, firstLine)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedUserData, mediaType)
+	}
+
+	var parts []userDataPart
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(p.Header.Get("Content-Type"), "multipart/") {
+			return nil, ErrNestedMultipartUserData
+		}
+		body, err := io.ReadAll(p)
+		if err != nil {
+			return nil, err
+		}
+		// Parts are written back decoded.
+		if strings.EqualFold(p.Header.Get("Content-Transfer-Encoding"), "base64") {
+			if body, err = base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(body), nil))); err != nil {
+				return nil, err
+			}
+		}
+		p.Header.Del("Content-Transfer-Encoding")
+		parts = append(parts, userDataPart{header: p.Header, body: body})
+	}
+}
+
+func isMultipart(data string) bool {
+	return strings.HasPrefix(data, "Content-Type: multipart/") || strings.HasPrefix(data, "MIME-Version:")
+}
+
+func writeMultipart(parts []userDataPart) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, part := range parts {
+		w, err := writer.CreatePart(part.header)
+		if err != nil {
+			return "", err
+		}
+		if _, err := w.Write(part.body); err != nil {
+			return "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	header := fmt.Sprintf("Content-Type: %s\r\nMIME-Version: 1.0\r\n\r\n",
+		mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": writer.Boundary()}))
+	return header + body.String(), nil
+}
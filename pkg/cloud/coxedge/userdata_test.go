@@ -0,0 +1,89 @@
+package coxedge
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+type testPart struct {
+	contentType string
+	mergeType   string
+	body        string
+}
+
+func readParts(t *testing.T, userData string) []testPart {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(userData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("expected multipart/mixed user data, got %q: %v", mediaType, err)
+	}
+	var parts []testPart
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contentType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		parts = append(parts, testPart{contentType: contentType, mergeType: p.Header.Get("Merge-Type"), body: string(body)})
+	}
+}
+
+func TestMergeUserData(t *testing.T) {
+	bootstrapData := "## template: jinja\n#cloud-config\nruncmd:\n- kubeadm init\n"
+
+	userData, err := MergeUserData(bootstrapData)
+	if err != nil || userData != bootstrapData {
+		t.Errorf("expected the bootstrap data as is without additional user data, got %q: %v", userData, err)
+	}
+
+	userData, err = MergeUserData(bootstrapData, "#cloud-config\npackages:\n- nfs-common\n", "#!/bin/sh\nsysctl -w vm.max_map_count=262144\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := readParts(t, userData)
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got %v", parts)
+	}
+	if parts[0].contentType != "text/jinja2" || parts[0].mergeType != "" || parts[0].body != bootstrapData {
+		t.Errorf("expected the bootstrap data first, unchanged, got %v", parts[0])
+	}
+	if parts[1].contentType != "text/cloud-config" || parts[1].mergeType != additionalUserDataMergeType {
+		t.Errorf("expected additional cloud-config to be appended to the bootstrap data, got %v", parts[1])
+	}
+	if parts[2].contentType != "text/x-shellscript" || !strings.Contains(parts[2].body, "vm.max_map_count") {
+		t.Errorf("expected the additional script, got %v", parts[2])
+	}
+
+	// Multipart user data is flattened.
+	merged, err := MergeUserData(userData, "#cloud-config\nmounts:\n- [/dev/vdb, /data]\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts := readParts(t, merged); len(parts) != 4 || parts[0].body != bootstrapData || parts[3].mergeType != additionalUserDataMergeType {
+		t.Errorf("expected the parts of multipart user data to be kept, got %v", parts)
+	}
+
+	if _, err := MergeUserData(bootstrapData, "packages: [nfs-common]"); !errors.Is(err, ErrUnsupportedUserData) {
+		t.Errorf("expected user data without a cloud-init header to be rejected, got %v", err)
+	}
+	if _, err := MergeUserData(bootstrapData, "#!/bin/sh\n#"+strings.Repeat("x", MaxUserDataSize)); !errors.Is(err, ErrUserDataTooLarge) {
+		t.Errorf("expected user data larger than the limit of Cox Edge to be rejected, got %v", err)
+	}
+}
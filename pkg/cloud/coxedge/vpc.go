@@ -0,0 +1,75 @@
+package coxedge
+
+const (
+	// VPCDefault is the slug of the VPC workloads are attached to by default.
+	VPCDefault = "default"
+
+	IPFamiliesIPv4      = "IPv4"
+	IPFamiliesIPv6      = "IPv6"
+	IPFamiliesDualStack = "IPv4,IPv6"
+)
+
+type VPCs struct {
+	Data []VPC `json:"data,omitempty"`
+}
+
+// VPC is a private network of the environment.
+type VPC struct {
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name"`
+	Slug   string `json:"slug"`
+	CIDR   string `json:"cidr,omitempty"`
+	Status string `json:"status,omitempty"`
+}
+
+type Subnets struct {
+	Data []Subnet `json:"data,omitempty"`
+}
+
+// Subnet is a range of addresses of a VPC.
+type Subnet struct {
+	ID     string `json:"id,omitempty"`
+	VPCID  string `json:"vpcId"`
+	Name   string `json:"name"`
+	Slug   string `json:"slug"`
+	CIDR   string `json:"cidr"`
+	Status string `json:"status,omitempty"`
+}
+
+// DefaultNetworkInterfaces returns the network interfaces of workloads that
+// do not configure any: a public IPv4 address in the default VPC.
+func DefaultNetworkInterfaces() []NetworkInterface {
+	return []NetworkInterface{
+		{
+			VPCSlug:    VPCDefault,
+			IPFamilies: IPFamiliesIPv4,
+			IsPublicIP: true,
+		},
+	}
+}
+
+func (c *Client) GetVPCBySlug(slug string) (*VPC, error) {
+	vpcs, err := c.GetVPCs()
+	if err != nil {
+		return nil, err
+	}
+	for _, vpc := range vpcs.Data {
+		if vpc.Slug == slug {
+			return &vpc, nil
+		}
+	}
+	return nil, ErrVPCNotFound
+}
+
+func (c *Client) GetSubnetBySlug(vpcID, slug string) (*Subnet, error) {
+	subnets, err := c.GetSubnets()
+	if err != nil {
+		return nil, err
+	}
+	for _, subnet := range subnets.Data {
+		if subnet.VPCID == vpcID && subnet.Slug == slug {
+			return &subnet, nil
+		}
+	}
+	return nil, ErrSubnetNotFound
+}
@@ -0,0 +1,21 @@
+package dns
+
+import (
+	"context"
+)
+
+const (
+	// DefaultTTL is the TTL used for records if none has been specified.
+	DefaultTTL = 60
+)
+
+// Provider manages the DNS records that point to a load balancer.
+type Provider interface {
+	// EnsureRecord makes sure that the A and AAAA records of fqdn resolve to
+	// exactly the given IPv4 and IPv6 addresses with the given TTL.
+	EnsureRecord(ctx context.Context, fqdn string, addresses []string, ttl uint32) error
+
+	// DeleteRecord removes all A and AAAA records of fqdn. It does not return an error
+	// if the record does not exist.
+	DeleteRecord(ctx context.Context, fqdn string) error
+}
@@ -0,0 +1,199 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultTSIGAlgorithm is used when a TSIG key is configured without an algorithm.
+	DefaultTSIGAlgorithm = dns.HmacSHA256
+
+	tsigFudge = 300
+)
+
+// addressTypes are the types of the record sets managed for a name.
+var addressTypes = []uint16{dns.TypeA, dns.TypeAAAA}
+
+// RFC2136Config configures a Provider that uses RFC2136 dynamic updates.
+type RFC2136Config struct {
+	// Server is the address (host:port) of the DNS server accepting updates.
+	Server string
+	// Zone that contains the managed records.
+	Zone string
+	// TSIGKeyName, TSIGSecret and TSIGAlgorithm are used to sign the updates.
+	// Updates are not signed if TSIGKeyName is empty.
+	TSIGKeyName   string
+	TSIGSecret    string
+	TSIGAlgorithm string
+	// Timeout for a single DNS exchange.
+	Timeout time.Duration
+}
+
+// RFC2136Provider is a Provider that manages records through RFC2136
+// dynamic updates, which are supported by BIND, PowerDNS, Knot and others.
+type RFC2136Provider struct {
+	config RFC2136Config
+	client *dns.Client
+}
+
+var _ Provider = (*RFC2136Provider)(nil)
+
+func NewRFC2136Provider(config RFC2136Config) (*RFC2136Provider, error) {
+	if config.Server == "" {
+		return nil, errors.New("rfc2136: server is required")
+	}
+	if config.Zone == "" {
+		return nil, errors.New("rfc2136: zone is required")
+	}
+	if _, _, err := net.SplitHostPort(config.Server); err != nil {
+		config.Server = net.JoinHostPort(config.Server, "53")
+	}
+	config.Zone = dns.Fqdn(config.Zone)
+
+	client := &dns.Client{
+		Net:     "tcp",
+		Timeout: config.Timeout,
+	}
+	if config.TSIGKeyName != "" {
+		config.TSIGKeyName = dns.Fqdn(config.TSIGKeyName)
+		if config.TSIGAlgorithm == "" {
+			config.TSIGAlgorithm = DefaultTSIGAlgorithm
+		}
+		config.TSIGAlgorithm = dns.Fqdn(config.TSIGAlgorithm)
+		client.TsigSecret = map[string]string{config.TSIGKeyName: config.TSIGSecret}
+	}
+
+	return &RFC2136Provider{
+		config: config,
+		client: client,
+	}, nil
+}
+
+func (p *RFC2136Provider) EnsureRecord(ctx context.Context, fqdn string, addresses []string, ttl uint32) error {
+	fqdn = dns.Fqdn(fqdn)
+	if !dns.IsSubDomain(p.config.Zone, fqdn) {
+		return errors.Errorf("rfc2136: %s is not part of zone %s", fqdn, p.config.Zone)
+	}
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	// IPv4 and IPv6 addresses are kept in separate A and AAAA record sets.
+	records := map[uint16][]dns.RR{}
+	desired := map[uint16][]string{}
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return errors.Errorf("rfc2136: %q is not a valid IP address", address)
+		}
+		header := dns.RR_Header{Name: fqdn, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl}
+		var record dns.RR = &dns.A{Hdr: header, A: ip}
+		if ip.To4() == nil {
+			header.Rrtype = dns.TypeAAAA
+			record = &dns.AAAA{Hdr: header, AAAA: ip}
+		}
+		records[header.Rrtype] = append(records[header.Rrtype], record)
+		desired[header.Rrtype] = append(desired[header.Rrtype], ip.String())
+	}
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(p.config.Zone)
+	changed := false
+	for _, rrtype := range addressTypes {
+		existing, existingTTL, err := p.lookup(ctx, fqdn, rrtype)
+		if err != nil {
+			return err
+		}
+		if equalAddresses(existing, desired[rrtype]) && (len(existing) == 0 || existingTTL == ttl) {
+			continue
+		}
+		changed = true
+		msg.RemoveRRset([]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: fqdn, Rrtype: rrtype, Class: dns.ClassINET}}})
+		if len(records[rrtype]) > 0 {
+			msg.Insert(records[rrtype])
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return p.exchange(ctx, msg)
+}
+
+func (p *RFC2136Provider) DeleteRecord(ctx context.Context, fqdn string) error {
+	fqdn = dns.Fqdn(fqdn)
+	msg := new(dns.Msg)
+	msg.SetUpdate(p.config.Zone)
+	for _, rrtype := range addressTypes {
+		msg.RemoveRRset([]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: fqdn, Rrtype: rrtype, Class: dns.ClassINET}}})
+	}
+	return p.exchange(ctx, msg)
+}
+
+// lookup queries the configured server directly for the A or AAAA records of
+// fqdn and their TTL, to avoid acting on stale data from caching resolvers.
+// Records with different TTLs are reported with a TTL of 0, so that they are
+// always updated.
+func (p *RFC2136Provider) lookup(ctx context.Context, fqdn string, rrtype uint16) ([]string, uint32, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(fqdn, rrtype)
+	resp, _, err := p.client.ExchangeContext(ctx, msg, p.config.Server)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "rfc2136: failed to look up %s", fqdn)
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, 0, errors.Errorf("rfc2136: failed to look up %s: %s", fqdn, dns.RcodeToString[resp.Rcode])
+	}
+
+	var addresses []string
+	var ttl uint32
+	for _, rr := range resp.Answer {
+		switch rr := rr.(type) {
+		case *dns.A:
+			addresses = append(addresses, rr.A.String())
+		case *dns.AAAA:
+			addresses = append(addresses, rr.AAAA.String())
+		default:
+			continue
+		}
+		if len(addresses) == 1 {
+			ttl = rr.Header().Ttl
+		} else if rr.Header().Ttl != ttl {
+			ttl = 0
+		}
+	}
+	return addresses, ttl, nil
+}
+
+func (p *RFC2136Provider) exchange(ctx context.Context, msg *dns.Msg) error {
+	if p.config.TSIGKeyName != "" {
+		msg.SetTsig(p.config.TSIGKeyName, p.config.TSIGAlgorithm, tsigFudge, time.Now().Unix())
+	}
+
+	resp, _, err := p.client.ExchangeContext(ctx, msg, p.config.Server)
+	if err != nil {
+		return errors.Wrap(err, "rfc2136: failed to send update")
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("rfc2136: update was refused: %s", dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+func equalAddresses(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
@@ -0,0 +1,258 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	testKeyName    = "capi-key."
+	testKeySecret  = "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0"
+	testRecordName = "api.cluster.example.com"
+)
+
+// fakeServer is a minimal authoritative server that applies RFC2136 updates
+// to an in-memory set of A and AAAA records.
+type fakeServer struct {
+	mu      sync.Mutex
+	records map[string][]dns.RR
+	updates int
+}
+
+// addresses returns the addresses of the records of the given name.
+func (f *fakeServer) addresses(name string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var addresses []string
+	for _, rr := range f.records[dns.Fqdn(name)] {
+		switch rr := rr.(type) {
+		case *dns.A:
+			addresses = append(addresses, rr.A.String())
+		case *dns.AAAA:
+			addresses = append(addresses, rr.AAAA.String())
+		}
+	}
+	return addresses
+}
+
+func (f *fakeServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	switch req.Opcode {
+	case dns.OpcodeQuery:
+		for _, q := range req.Question {
+			for _, rr := range f.records[q.Name] {
+				if rr.Header().Rrtype == q.Qtype {
+					resp.Answer = append(resp.Answer, rr)
+				}
+			}
+		}
+	case dns.OpcodeUpdate:
+		if req.IsTsig() == nil || w.TsigStatus() != nil {
+			resp.Rcode = dns.RcodeRefused
+			break
+		}
+		f.updates++
+		for _, rr := range req.Ns {
+			name := rr.Header().Name
+			switch rr.Header().Class {
+			case dns.ClassANY:
+				var kept []dns.RR
+				for _, existing := range f.records[name] {
+					if existing.Header().Rrtype != rr.Header().Rrtype {
+						kept = append(kept, existing)
+					}
+				}
+				f.records[name] = kept
+				if len(kept) == 0 {
+					delete(f.records, name)
+				}
+			case dns.ClassINET:
+				f.records[name] = append(f.records[name], rr)
+			}
+		}
+	}
+	if req.IsTsig() != nil {
+		resp.SetTsig(testKeyName, dns.HmacSHA256, tsigFudge, time.Now().Unix())
+	}
+	_ = w.WriteMsg(resp)
+}
+
+func startFakeServer(t *testing.T) (*fakeServer, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeServer{records: map[string][]dns.RR{}}
+	started := make(chan struct{})
+	server := &dns.Server{
+		Listener:          listener,
+		Handler:           fake,
+		TsigSecret:        map[string]string{testKeyName: testKeySecret},
+		NotifyStartedFunc: func() { close(started) },
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction {
+			return dns.MsgAccept
+		},
+	}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	<-started
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return fake, listener.Addr().String()
+}
+
+func newTestProvider(t *testing.T, addr string, secret string) *RFC2136Provider {
+	t.Helper()
+	p, err := NewRFC2136Provider(RFC2136Config{
+		Server:      addr,
+		Zone:        "example.com",
+		TSIGKeyName: "capi-key",
+		TSIGSecret:  secret,
+		Timeout:     5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRFC2136EnsureRecord(t *testing.T) {
+	fake, addr := startFakeServer(t)
+	p := newTestProvider(t, addr, testKeySecret)
+	ctx := context.Background()
+
+	if err := p.EnsureRecord(ctx, testRecordName, []string{"192.0.2.1"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.addresses(testRecordName); len(got) != 1 || got[0] != "192.0.2.1" {
+		t.Fatalf("unexpected records after create: %v", got)
+	}
+
+	// An unchanged record should not result in another update.
+	if err := p.EnsureRecord(ctx, testRecordName, []string{"192.0.2.1"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if fake.updates != 1 {
+		t.Fatalf("expected 1 update, got %d", fake.updates)
+	}
+
+	if err := p.EnsureRecord(ctx, testRecordName, []string{"192.0.2.2", "192.0.2.3"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.addresses(testRecordName); !equalAddresses(got, []string{"192.0.2.3", "192.0.2.2"}) {
+		t.Fatalf("unexpected records after update: %v", got)
+	}
+
+	if err := p.DeleteRecord(ctx, testRecordName); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := fake.records[dns.Fqdn(testRecordName)]; ok {
+		t.Fatalf("expected record to be deleted, got %v", got)
+	}
+}
+
+func TestRFC2136EnsureRecordTTL(t *testing.T) {
+	fake, addr := startFakeServer(t)
+	p := newTestProvider(t, addr, testKeySecret)
+	ctx := context.Background()
+
+	if err := p.EnsureRecord(ctx, testRecordName, []string{"192.0.2.1", "2001:db8::1"}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A changed TTL is applied, even if the addresses did not change.
+	if err := p.EnsureRecord(ctx, testRecordName, []string{"192.0.2.1", "2001:db8::1"}, 300); err != nil {
+		t.Fatal(err)
+	}
+	if fake.updates != 2 {
+		t.Fatalf("expected 2 updates, got %d", fake.updates)
+	}
+	for _, rr := range fake.records[dns.Fqdn(testRecordName)] {
+		if rr.Header().Ttl != 300 {
+			t.Errorf("expected a TTL of 300, got %s", rr)
+		}
+	}
+
+	if err := p.EnsureRecord(ctx, testRecordName, []string{"192.0.2.1", "2001:db8::1"}, 300); err != nil {
+		t.Fatal(err)
+	}
+	if fake.updates != 2 {
+		t.Fatalf("expected an unchanged TTL not to be updated, got %d updates", fake.updates)
+	}
+}
+
+func TestRFC2136EnsureRecordDualStack(t *testing.T) {
+	fake, addr := startFakeServer(t)
+	p := newTestProvider(t, addr, testKeySecret)
+	ctx := context.Background()
+
+	if err := p.EnsureRecord(ctx, testRecordName, []string{"192.0.2.1", "2001:db8::1"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.addresses(testRecordName); !equalAddresses(got, []string{"192.0.2.1", "2001:db8::1"}) {
+		t.Fatalf("unexpected records after create: %v", got)
+	}
+
+	// Addresses in a non-canonical form are not a change.
+	if err := p.EnsureRecord(ctx, testRecordName, []string{"192.0.2.1", "2001:DB8:0::1"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if fake.updates != 1 {
+		t.Fatalf("expected 1 update, got %d", fake.updates)
+	}
+
+	// Changing the IPv6 address keeps the A record.
+	if err := p.EnsureRecord(ctx, testRecordName, []string{"192.0.2.1", "2001:db8::2"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.addresses(testRecordName); !equalAddresses(got, []string{"192.0.2.1", "2001:db8::2"}) {
+		t.Fatalf("unexpected records after update: %v", got)
+	}
+
+	if err := p.EnsureRecord(ctx, testRecordName, []string{"2001:db8::2"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.addresses(testRecordName); !equalAddresses(got, []string{"2001:db8::2"}) {
+		t.Fatalf("unexpected records after removing the IPv4 address: %v", got)
+	}
+
+	if err := p.DeleteRecord(ctx, testRecordName); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.addresses(testRecordName); len(got) > 0 {
+		t.Fatalf("expected records to be deleted, got %v", got)
+	}
+}
+
+func TestRFC2136InvalidInput(t *testing.T) {
+	_, addr := startFakeServer(t)
+	p := newTestProvider(t, addr, testKeySecret)
+
+	if err := p.EnsureRecord(context.Background(), "api.example.org", []string{"192.0.2.1"}, 0); err == nil {
+		t.Fatal("expected an error for a record outside of the zone")
+	}
+	if err := p.EnsureRecord(context.Background(), testRecordName, []string{"not-an-ip"}, 0); err == nil {
+		t.Fatal("expected an error for an invalid address")
+	}
+}
+
+func TestRFC2136BadKey(t *testing.T) {
+	_, addr := startFakeServer(t)
+	p := newTestProvider(t, addr, "d3JvbmdrZXl3cm9uZ2tleXdyb25na2V5")
+
+	if err := p.EnsureRecord(context.Background(), testRecordName, []string{"192.0.2.1"}, 0); err == nil {
+		t.Fatal("expected the update to be refused")
+	}
+}